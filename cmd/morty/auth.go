@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"savemorty/internal/client"
+)
+
+// errNoAuth is returned by authFlags.resolve when no credential is set.
+var errNoAuth = errors.New("no auth configured: set AUTH_HEADER, -auth or -auth-file")
+
+// errAuthConflict is returned by authFlags.resolve when both -auth and
+// -auth-file are set, since it is unclear which was meant.
+var errAuthConflict = errors.New("-auth and -auth-file are both set, use one")
+
+// authFlags are the flags a credential can be given with.
+type authFlags struct {
+	value *string
+	file  *string
+}
+
+// addAuthFlags registers -auth and -auth-file on fs.
+func addAuthFlags(fs *flag.FlagSet) authFlags {
+	return authFlags{
+		value: fs.String("auth", "", "Authorization header value (overrides AUTH_HEADER, not with -auth-file)"),
+		file:  fs.String("auth-file", "", "read the Authorization header value from this file (overrides AUTH_HEADER, not with -auth)"),
+	}
+}
+
+// provider returns an AuthProvider for the source resolve picked, re-reading
+// the file or environment variable on every request.
+func (a authFlags) provider() client.AuthProvider {
+	switch {
+	case *a.value != "":
+		return client.StaticAuth(*a.value)
+	case *a.file != "":
+		return client.FileAuth(*a.file)
+	default:
+		return client.EnvAuth("AUTH_HEADER")
+	}
+}
+
+// resolve returns the Authorization header value from -auth or -auth-file,
+// or else AUTH_HEADER. Setting both flags is an error.
+func (a authFlags) resolve() (string, error) {
+	if *a.value != "" && *a.file != "" {
+		return "", errAuthConflict
+	}
+	if *a.value != "" {
+		return *a.value, nil
+	}
+	if *a.file != "" {
+		b, err := os.ReadFile(*a.file)
+		if err != nil {
+			return "", fmt.Errorf("reading -auth-file: %w", err)
+		}
+		v := strings.TrimSpace(string(b))
+		if v == "" {
+			return "", fmt.Errorf("-auth-file %s is empty", *a.file)
+		}
+		return v, nil
+	}
+	if v := os.Getenv("AUTH_HEADER"); v != "" {
+		return v, nil
+	}
+	return "", errNoAuth
+}
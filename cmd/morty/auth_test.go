@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuthResolve(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "token")
+	if err := os.WriteFile(file, []byte("  from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     string
+		want    string
+		wantErr error
+	}{
+		{name: "env", env: "from-env", want: "from-env"},
+		{name: "flag over env", args: []string{"-auth", "from-flag"}, env: "from-env", want: "from-flag"},
+		{name: "file over env", args: []string{"-auth-file", file}, env: "from-env", want: "from-file"},
+		{name: "file", args: []string{"-auth-file", file}, want: "from-file"},
+		{name: "both flags", args: []string{"-auth", "from-flag", "-auth-file", file}, wantErr: errAuthConflict},
+		{name: "both flags and env", args: []string{"-auth", "from-flag", "-auth-file", file}, env: "from-env", wantErr: errAuthConflict},
+		{name: "nothing", wantErr: errNoAuth},
+		{name: "empty file", args: []string{"-auth-file", empty}, env: "from-env"},
+		{name: "missing file", args: []string{"-auth-file", filepath.Join(dir, "missing")}, env: "from-env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AUTH_HEADER", tt.env)
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			auth := addAuthFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			got, err := auth.resolve()
+			if tt.want == "" && tt.wantErr == nil {
+				// A bad -auth-file fails rather than falling back.
+				if err == nil {
+					t.Fatalf("resolve = %q, want an error", got)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("resolve = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
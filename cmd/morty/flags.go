@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"savemorty/internal/bandit"
+)
+
+// planetList is a repeatable flag of planet indexes.
+type planetList []int
+
+func (l *planetList) String() string {
+	return fmt.Sprint([]int(*l))
+}
+
+func (l *planetList) Set(s string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("invalid planet %q", s)
+	}
+	*l = append(*l, n)
+	return nil
+}
+
+// comboList is a repeatable flag of combos written as "3,0,1".
+type comboList [][3]int
+
+func (l *comboList) String() string {
+	return fmt.Sprint([][3]int(*l))
+}
+
+func (l *comboList) Set(s string) error {
+	combo, err := bandit.ParseCombo(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, combo)
+	return nil
+}
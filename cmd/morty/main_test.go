@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"savemorty/internal/client"
+	"savemorty/internal/episode"
+	"savemorty/internal/heartbeat"
+	"savemorty/internal/simulator"
+)
+
+// TestMain runs main instead of the tests when re-executed by runMorty.
+func TestMain(m *testing.M) {
+	if os.Getenv("MORTY_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMorty runs the command with args and extra environment variables, and
+// returns its stdout and exit code.
+func runMorty(t *testing.T, env []string, args ...string) (string, int) {
+	t.Helper()
+	stdout, _, code := runMortyLogs(t, env, args...)
+	return stdout, code
+}
+
+// runMortyLogs is runMorty that also returns the logs written to stderr.
+func runMortyLogs(t *testing.T, env []string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "AUTH_HEADER=") && !strings.HasPrefix(kv, "MORTY_") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.Env = append(cmd.Env, "MORTY_TEST_MAIN=1")
+	cmd.Env = append(cmd.Env, env...)
+	var out, logs bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &logs
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatal(err)
+	}
+	return out.String(), logs.String(), cmd.ProcessState.ExitCode()
+}
+
+func TestRunPorcelainOnEveryExit(t *testing.T) {
+	sim := httptest.NewServer(simulator.New(simulator.Config{Planets: [3]float64{0.9, 0.5, 0.2}, Morties: 20}))
+	t.Cleanup(sim.Close)
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"detail":"invalid token"}`))
+	}))
+	t.Cleanup(unauthorized.Close)
+
+	// Nothing listens on the port once the listener is closed.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + ln.Addr().String()
+	ln.Close()
+
+	// A fresh heartbeat from the same token means another run is playing.
+	beatPath := filepath.Join(t.TempDir(), "beat.json")
+	if err := heartbeat.New(beatPath, "tok").Write(3, 10, heartbeat.PhaseRunning); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		env      []string
+		args     []string
+		wantCode int
+		wantLine string
+	}{
+		{name: "no auth", args: []string{"-base-url", sim.URL}, wantCode: 2, wantLine: "v1\tincomplete\t0\t0\t0\t0"},
+		{name: "conflicting auth flags", args: []string{"-base-url", sim.URL, "-auth", "tok", "-auth-file", "tok.txt"}, wantCode: 2, wantLine: "v1\tincomplete\t0\t0\t0\t0"},
+		{name: "bad base url", env: []string{"AUTH_HEADER=tok"}, args: []string{"-base-url", "ftp://x"}, wantCode: 2, wantLine: "v1\tincomplete\t0\t0\t0\t0"},
+		{name: "bad proxy", env: []string{"AUTH_HEADER=tok"}, args: []string{"-proxy", "ftp://p"}, wantCode: 2, wantLine: "v1\tincomplete\t0\t0\t0\t0"},
+		{name: "bad max requests env", env: []string{"AUTH_HEADER=tok", "MORTY_MAX_REQUESTS=lots"}, wantCode: 2, wantLine: "v1\tincomplete\t0\t0\t0\t0"},
+		{name: "bad on-existing", env: []string{"AUTH_HEADER=tok"}, args: []string{"-on-existing", "maybe"}, wantCode: 2, wantLine: "v1\tincomplete\t0\t0\t0\t0"},
+		{name: "negative trace body limit", env: []string{"AUTH_HEADER=tok"}, args: []string{"-trace-body-limit", "-1"}, wantCode: 2, wantLine: "v1\tincomplete\t0\t0\t0\t0"},
+		{name: "heartbeat collision", env: []string{"AUTH_HEADER=tok"}, args: []string{"-base-url", sim.URL, "-heartbeat-file", beatPath}, wantCode: 1, wantLine: "v1\tincomplete\t0\t0\t0\t0"},
+		{name: "unauthorized", env: []string{"AUTH_HEADER=tok"}, args: []string{"-base-url", unauthorized.URL, "-max-retries", "0"}, wantCode: 2, wantLine: "v1\tincomplete\t0\t0\t0\t0"},
+		{name: "connection refused", env: []string{"AUTH_HEADER=tok"}, args: []string{"-base-url", closed, "-retry-base", "1ms", "-breaker-cooldown", "1ms"}, wantCode: 1, wantLine: "v1\tincomplete\t0\t0\t0\t0"},
+		{name: "completed", env: []string{"AUTH_HEADER=tok"}, args: []string{"-base-url", sim.URL}, wantCode: 0, wantLine: "v1\tcompleted\t"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, code := runMorty(t, tt.env, append([]string{"-porcelain"}, tt.args...)...)
+			if code != tt.wantCode {
+				t.Errorf("exit code %d, want %d", code, tt.wantCode)
+			}
+			lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+			if len(lines) != 1 || !strings.HasPrefix(lines[0], tt.wantLine) {
+				t.Errorf("stdout = %q, want one line starting %q", stdout, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestStatusPorcelainOnEveryExit(t *testing.T) {
+	sim := httptest.NewServer(simulator.New(simulator.Config{Planets: [3]float64{0.9, 0.5, 0.2}, Morties: 20}))
+	t.Cleanup(sim.Close)
+	empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"morties_in_citadel":0,"morties_on_planet_jessica":0,"morties_lost":0,"steps_taken":0,"status_message":""}`))
+	}))
+	t.Cleanup(empty.Close)
+	tests := []struct {
+		name     string
+		env      []string
+		args     []string
+		wantCode int
+	}{
+		{name: "no auth", args: []string{"-base-url", sim.URL}, wantCode: statusExitAuth},
+		{name: "bad base url", env: []string{"AUTH_HEADER=tok"}, args: []string{"-base-url", "ftp://x"}, wantCode: statusExitUnknown},
+		{name: "no episode", env: []string{"AUTH_HEADER=tok"}, args: []string{"-base-url", sim.URL}, wantCode: statusExitUnknown},
+		{name: "all-zero status", env: []string{"AUTH_HEADER=tok"}, args: []string{"-base-url", empty.URL}, wantCode: statusExitUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, code := runMorty(t, tt.env, append([]string{"status", "-porcelain"}, tt.args...)...)
+			if code != tt.wantCode {
+				t.Errorf("exit code %d, want %d", code, tt.wantCode)
+			}
+			if want := "v1\tunknown\t0\t0\t0\t0\t0.000\n"; stdout != want {
+				t.Errorf("stdout = %q, want %q", stdout, want)
+			}
+		})
+	}
+}
+
+func TestRunExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: nil, want: 0},
+		{err: fmt.Errorf("%w: %w", episode.ErrStart, context.Canceled), want: 130},
+		{err: fmt.Errorf("step 3: %w", &client.PlanetError{Planet: 1, Err: context.Canceled}), want: 130},
+		{err: fmt.Errorf("%w: %w", episode.ErrStart, &client.APIError{StatusCode: http.StatusUnauthorized}), want: 2},
+		{err: fmt.Errorf("%w: %w", episode.ErrStart, &client.APIError{StatusCode: http.StatusNotFound}), want: 1},
+		{err: episode.ErrEpisodeInProgress, want: 1},
+		{err: episode.ErrNoMorties, want: 1},
+		{err: episode.ErrInfeasible, want: 2},
+		{err: fmt.Errorf("step 4: fetching status: %w", episode.ErrEmptyStatus), want: 1},
+		{err: episode.ErrRequestCap, want: 3},
+		{err: fmt.Errorf("step 3: %w", &client.PlanetError{Planet: 0, Err: client.ErrRequestCap}), want: 3},
+		{err: errors.New("boom"), want: 1},
+	}
+	for _, tt := range tests {
+		if got := runExitCode(tt.err); got != tt.want {
+			t.Errorf("runExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+// logSaved returns the saved count logged with msg, or -1 if it was not.
+func logSaved(logs, msg string) int {
+	// The text handler only quotes messages that need it.
+	quoted := `(?:` + regexp.QuoteMeta(msg) + `|` + regexp.QuoteMeta(strconv.Quote(msg)) + `)`
+	m := regexp.MustCompile(`msg=` + quoted + ` .*\bsaved=(\d+)`).FindStringSubmatch(logs)
+	if m == nil {
+		return -1
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
+
+func TestSavedAgreesEverywhere(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		// wantStatus is the status subcommand's exit code afterwards.
+		wantStatus int
+	}{
+		{name: "completed", wantCode: 0, wantStatus: statusExitCompleted},
+		// Stopping at the request cap leaves the episode in progress.
+		{name: "stopped early", args: []string{"-max-requests", "40"}, wantCode: 3, wantStatus: statusExitInProgress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Injected 500s come before any game state changes, so
+			// retrying portal sends is safe here.
+			sim := httptest.NewServer(simulator.New(simulator.Config{Planets: [3]float64{0.9, 0.5, 0.2}, Morties: 100, FailRate: 0.1}))
+			t.Cleanup(sim.Close)
+			env := []string{"AUTH_HEADER=tok"}
+			args := append([]string{"-base-url", sim.URL, "-porcelain", "-retry-portal", "-max-retries", "20", "-retry-base", "1ms", "-breaker-threshold", "0"}, tt.args...)
+			stdout, logs, code := runMortyLogs(t, env, args...)
+			if code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d\n%s", code, tt.wantCode, logs)
+			}
+
+			fields := strings.Split(strings.TrimSpace(stdout), "\t")
+			if len(fields) < 3 {
+				t.Fatalf("porcelain line %q", stdout)
+			}
+			porcelain, _ := strconv.Atoi(fields[2])
+			summary := logSaved(logs, "episode finished")
+			exitMsg := logSaved(logs, "exiting")
+
+			// The status subcommand makes a single attempt, so read it
+			// until an injected 500 does not get in the way.
+			var out statusOutput
+			for range 20 {
+				stdout, code := runMorty(t, env, "status", "-json", "-compact", "-base-url", sim.URL)
+				if code == tt.wantStatus {
+					if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+						t.Fatal(err)
+					}
+					break
+				}
+			}
+			status := out.Saved()
+
+			if status == 0 || summary != status || exitMsg != status || porcelain != status {
+				t.Errorf("saved: log summary %d, exit message %d, porcelain %d, status -json %d, want all the same and non-zero",
+					summary, exitMsg, porcelain, status)
+			}
+		})
+	}
+}
+
+func TestStartWithNoMorties(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		start  string
+	}{
+		{
+			// The token is valid but may not play, so nothing has
+			// started and the start hands back an empty Citadel.
+			name:   "bad token scope",
+			status: `{"morties_in_citadel":0,"morties_on_planet_jessica":0,"morties_lost":0,"steps_taken":0,"status_message":""}`,
+			start:  `{"morties_in_citadel":0,"morties_on_planet_jessica":0,"morties_lost":0,"steps_taken":0,"status_message":"token lacks the play scope"}`,
+		},
+		{
+			// The server will not start over an episode that has ended.
+			name:   "already finished",
+			status: `{"morties_in_citadel":0,"morties_on_planet_jessica":612,"morties_lost":388,"steps_taken":120,"status_message":"episode complete"}`,
+			start:  `{"morties_in_citadel":0,"morties_on_planet_jessica":612,"morties_lost":388,"steps_taken":120,"status_message":"episode complete"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var starts, portals atomic.Int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch r.URL.Path {
+				case "/api/mortys/start/":
+					starts.Add(1)
+					w.Write([]byte(tt.start))
+				case "/api/mortys/status/":
+					w.Write([]byte(tt.status))
+				default:
+					portals.Add(1)
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"detail":"no episode"}`))
+				}
+			}))
+			t.Cleanup(srv.Close)
+
+			stdout, logs, code := runMortyLogs(t, []string{"AUTH_HEADER=tok"}, "-base-url", srv.URL, "-porcelain")
+			if code != 1 {
+				t.Errorf("exit code %d, want 1", code)
+			}
+			if !strings.HasPrefix(stdout, "v1\tincomplete\t") {
+				t.Errorf("stdout = %q, want an incomplete run", stdout)
+			}
+			// The start is tried once more, then nothing is played.
+			if got := starts.Load(); got != 2 {
+				t.Errorf("start called %d times, want 2", got)
+			}
+			if got := portals.Load(); got != 0 {
+				t.Errorf("%d portal requests, want none", got)
+			}
+			if !strings.Contains(logs, "nothing was played") {
+				t.Errorf("logs do not say nothing was played:\n%s", logs)
+			}
+		})
+	}
+}
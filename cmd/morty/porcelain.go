@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"savemorty/internal/client"
+)
+
+// Porcelain output is for scripts. Each format is one line of tab-separated
+// fields on stdout, starting with its version; fields are only ever added at
+// the end, and any other change bumps the version. The golden files in
+// testdata pin every format down. The line is printed however the command
+// ends, with zero counts if it failed before reading any; the exit code says
+// why. Logs never go to stdout.
+const porcelainVersion = "v1"
+
+// writeStatusPorcelain writes the status subcommand's porcelain line:
+//
+//	v1 <phase> <morties_in_citadel> <morties_on_planet_jessica> <morties_lost> <steps_taken> <saved_rate>
+//
+// phase is unknown, in_progress or completed; saved_rate has three decimals.
+func writeStatusPorcelain(w io.Writer, s client.Status) {
+	fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%.3f\n",
+		porcelainVersion, s.Phase(), s.MortiesInCitadel, s.MortiesOnPlanetJessica, s.MortiesLost, s.StepsTaken, s.SavedRate())
+}
+
+// writeRunPorcelain writes the line a run prints when it ends, whether or not
+// the episode finished:
+//
+//	v1 <outcome> <saved> <lost> <remaining> <steps_taken>
+//
+// outcome is completed if no Morties remain, otherwise incomplete; the exit
+// code says why.
+func writeRunPorcelain(w io.Writer, s client.Status) {
+	outcome := "completed"
+	if s.MortiesInCitadel > 0 || s.Phase() == client.PhaseUnknown {
+		outcome = "incomplete"
+	}
+	fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\n",
+		porcelainVersion, outcome, s.Saved(), s.MortiesLost, s.MortiesInCitadel, s.StepsTaken)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"savemorty/internal/client"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// porcelainStatuses cover every phase and outcome.
+var porcelainStatuses = []client.Status{
+	{},
+	{MortiesInCitadel: 1000},
+	{MortiesInCitadel: 412, MortiesOnPlanetJessica: 390, MortiesLost: 198, StepsTaken: 66},
+	{MortiesInCitadel: 0, MortiesOnPlanetJessica: 712, MortiesLost: 288, StepsTaken: 120},
+	{MortiesInCitadel: 0, MortiesOnPlanetJessica: 0, MortiesLost: 1000, StepsTaken: 111},
+}
+
+// checkGolden compares got with testdata/name, or rewrites it with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s changed, which needs a new porcelain version:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestRunPorcelainGolden(t *testing.T) {
+	var buf bytes.Buffer
+	for _, s := range porcelainStatuses {
+		writeRunPorcelain(&buf, s)
+	}
+	checkGolden(t, "run_porcelain.golden", buf.Bytes())
+}
+
+func TestStatusPorcelainGolden(t *testing.T) {
+	var buf bytes.Buffer
+	for _, s := range porcelainStatuses {
+		writeStatusPorcelain(&buf, s)
+	}
+	checkGolden(t, "status_porcelain.golden", buf.Bytes())
+}
+
+// statusJSONFields is the field set of status -json; scripts rely on it.
+var statusJSONFields = []string{
+	"morties_in_citadel", "morties_lost", "morties_on_planet_jessica",
+	"phase", "saved_rate", "status_message", "steps_taken",
+}
+
+func TestStatusJSONCompactGolden(t *testing.T) {
+	var buf bytes.Buffer
+	for _, s := range porcelainStatuses {
+		var line bytes.Buffer
+		if err := writeStatusJSON(&line, newStatusOutput(s), true); err != nil {
+			t.Fatal(err)
+		}
+		if n := bytes.Count(line.Bytes(), []byte("\n")); n != 1 || !bytes.HasSuffix(line.Bytes(), []byte("\n")) {
+			t.Errorf("compact JSON for %+v is not a single line:\n%s", s, line.Bytes())
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(line.Bytes(), &fields); err != nil {
+			t.Fatal(err)
+		}
+		if got := slices.Sorted(maps.Keys(fields)); !slices.Equal(got, statusJSONFields) {
+			t.Errorf("compact JSON fields = %v, want %v", got, statusJSONFields)
+		}
+		buf.Write(line.Bytes())
+	}
+	checkGolden(t, "status_json_compact.golden", buf.Bytes())
+}
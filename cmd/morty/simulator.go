@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"savemorty/internal/simulator"
+)
+
+// runSimulator serves the simulated challenge API until the listener fails.
+func runSimulator(args []string) int {
+	fs := flag.NewFlagSet("simulator", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8000", "address to listen on; the admin endpoint has no auth, so think twice before exposing it")
+	planets := fs.String("planets", "0.9,0.5,0.2", "survival chance of a batch sent to each planet")
+	morties := fs.Int("mortys", 1000, "Morties in the Citadel at the start of each episode")
+	failRate := fs.Float64("fail-rate", 0, "fraction of requests answered with a 500")
+	drift := fs.Float64("drift", 0, "let each planet's survival chance wander by up to this much after every portal request (0 keeps them fixed)")
+	fs.Parse(args)
+
+	odds, err := parsePlanets(*planets)
+	if err != nil {
+		slog.Error("invalid -planets", "error", err)
+		return 2
+	}
+	if *drift < 0 || *drift > 1 {
+		slog.Error("invalid -drift, must be between 0 and 1", "drift", *drift)
+		return 2
+	}
+	srv := simulator.New(simulator.Config{
+		Planets:  odds,
+		Morties:  *morties,
+		FailRate: *failRate,
+		Drift:    *drift,
+	})
+	slog.Info("simulator listening", "addr", *addr, "planets", odds, "mortys", *morties, "drift", *drift)
+	if err := http.ListenAndServe(*addr, srv); err != nil {
+		slog.Error("simulator stopped", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// parsePlanets parses three comma separated probabilities.
+func parsePlanets(s string) ([3]float64, error) {
+	var odds [3]float64
+	parts := strings.Split(s, ",")
+	if len(parts) != len(odds) {
+		return odds, fmt.Errorf("%q must have %d comma separated probabilities", s, len(odds))
+	}
+	for i, part := range parts {
+		p, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || p < 0 || p > 1 {
+			return odds, fmt.Errorf("%q is not a probability", part)
+		}
+		odds[i] = p
+	}
+	return odds, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"savemorty/internal/client"
+)
+
+// Exit codes for the status subcommand.
+const (
+	statusExitInProgress = 0
+	statusExitUnknown    = 1
+	statusExitAuth       = 2
+	statusExitCompleted  = 3
+)
+
+// statusOutput is the JSON printed by the status subcommand.
+type statusOutput struct {
+	client.Status
+	SavedRate float64 `json:"saved_rate"`
+	Phase     string  `json:"phase"`
+}
+
+// newStatusOutput derives the JSON fields from status.
+func newStatusOutput(status client.Status) statusOutput {
+	return statusOutput{
+		Status:    status,
+		SavedRate: status.SavedRate(),
+		Phase:     status.Phase(),
+	}
+}
+
+// writeStatusJSON writes out as JSON, indented or on a single line.
+func writeStatusJSON(w io.Writer, out statusOutput, compact bool) error {
+	enc := json.NewEncoder(w)
+	if !compact {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(out)
+}
+
+// runStatus prints the current episode status to stdout and returns the exit
+// code for its phase.
+func runStatus(args []string) int {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the status as JSON")
+	compact := fs.Bool("compact", false, "with -json, print a single line")
+	porcelain := fs.Bool("porcelain", false, "print one stable tab-separated line for scripts (see porcelain.go)")
+	httpTimeout := fs.Duration("http-timeout", client.DefaultTimeout, "timeout for the HTTP request (0 disables)")
+	baseURL := fs.String("base-url", envString("MORTY_BASE_URL", client.DefaultBaseURL), "challenge API to ask (env MORTY_BASE_URL)")
+	proxy := fs.String("proxy", "", "send the request through this proxy URL (default: HTTPS_PROXY and friends)")
+	auth := addAuthFlags(fs)
+	fs.Parse(args)
+
+	// fail prints the porcelain line for an unknown status if asked to, so
+	// scripts get one however the call ends.
+	fail := func(code int) int {
+		if *porcelain {
+			writeStatusPorcelain(os.Stdout, client.Status{})
+		}
+		return code
+	}
+
+	authHeader, err := auth.resolve()
+	if err != nil {
+		slog.Error("cannot authenticate", "error", err)
+		return fail(statusExitAuth)
+	}
+
+	apiURL, err := client.ParseBaseURL(*baseURL)
+	if err != nil {
+		slog.Error("invalid -base-url", "error", err)
+		return fail(statusExitUnknown)
+	}
+	transport, err := newTransport(*proxy)
+	if err != nil {
+		slog.Error("invalid -proxy", "error", err)
+		return fail(statusExitUnknown)
+	}
+	httpClient := &http.Client{Timeout: *httpTimeout}
+	c := client.NewClient(apiURL, authHeader, httpClient, client.WithTransport(transport))
+	status, err := c.Status(context.Background())
+	if err != nil {
+		slog.Error("error fetching status", "error", err)
+		var apiErr *client.APIError
+		if errors.As(err, &apiErr) && apiErr.Unauthorized() {
+			return fail(statusExitAuth)
+		}
+		return fail(statusExitUnknown)
+	}
+	out := newStatusOutput(status)
+
+	switch {
+	case *porcelain:
+		writeStatusPorcelain(os.Stdout, status)
+	case *asJSON:
+		writeStatusJSON(os.Stdout, out, *compact)
+	default:
+		fmt.Printf("phase:                     %s\n", out.Phase)
+		fmt.Printf("morties_in_citadel:        %d\n", out.MortiesInCitadel)
+		fmt.Printf("morties_on_planet_jessica: %d\n", out.MortiesOnPlanetJessica)
+		fmt.Printf("morties_lost:              %d\n", out.MortiesLost)
+		fmt.Printf("steps_taken:               %d\n", out.StepsTaken)
+		fmt.Printf("saved_rate:                %.3f\n", out.SavedRate)
+	}
+
+	switch out.Phase {
+	case client.PhaseInProgress:
+		return statusExitInProgress
+	case client.PhaseCompleted:
+		return statusExitCompleted
+	default:
+		return statusExitUnknown
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// maxIdleConnsPerHost keeps a few spare connections to the API. A step is
+// four requests to one host in a row, so one is usually all that is used.
+const maxIdleConnsPerHost = 4
+
+// idleConnTimeout outlasts the default circuit breaker cooldown, so a pause
+// does not cost a new TLS handshake.
+const idleConnTimeout = 2 * time.Minute
+
+// newTransport returns the transport for API requests, tuned to reuse its
+// connection between steps. Without a proxy it honours HTTPS_PROXY and
+// friends like http.DefaultTransport.
+func newTransport(proxy string) (http.RoundTripper, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	t.IdleConnTimeout = idleConnTimeout
+	if proxy == "" {
+		return t, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: scheme must be http, https or socks5", proxy)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: no host", proxy)
+	}
+	t.Proxy = http.ProxyURL(u)
+	return t, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"savemorty/internal/client"
+	"savemorty/internal/simulator"
+)
+
+func TestTransportReusesConnections(t *testing.T) {
+	sim := simulator.New(simulator.Config{Planets: [3]float64{0.9, 0.5, 0.2}, Morties: 1000})
+	// Every fifth status read fails with a page a proxy might send, so
+	// bodies that are never decoded must be drained too.
+	var statuses atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && statuses.Add(1)%5 == 0 {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(strings.Repeat("<p>bad gateway</p>", 3000)))
+			return
+		}
+		sim.ServeHTTP(w, r)
+	}))
+	var conns atomic.Int64
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	transport, err := newTransport("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := client.NewClient(srv.URL, "token", &http.Client{Timeout: 5 * time.Second},
+		client.WithTransport(transport),
+		client.WithRetry(2, time.Millisecond, false),
+	)
+	ctx := context.Background()
+	if _, err := c.StartEpisode(ctx); err != nil {
+		t.Fatal(err)
+	}
+	const steps = 50
+	for step := range steps {
+		if _, err := c.SendMorties(ctx, [3]int{3, 3, 3}); err != nil {
+			t.Fatalf("step %d: %v", step, err)
+		}
+		if _, err := c.Status(ctx); err != nil {
+			t.Fatalf("step %d: %v", step, err)
+		}
+	}
+	if got := c.Requests(); got < 4*steps {
+		t.Fatalf("made %d requests, want at least %d", got, 4*steps)
+	}
+	if got := conns.Load(); got > 2 {
+		t.Errorf("%d requests opened %d connections, want at most 2", c.Requests(), got)
+	}
+}
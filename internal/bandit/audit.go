@@ -0,0 +1,42 @@
+package bandit
+
+import "math"
+
+// auditZ is the two-sided z-score beyond which the realized exploration
+// fraction is treated as a probable bug (p < 0.001).
+const auditZ = 3.29
+
+// ExplorationAudit compares how often the loop explored with how often the
+// epsilon coin flip says it should have.
+type ExplorationAudit struct {
+	// Eligible is the number of steps decided by the coin flip. Forced,
+	// frozen and warmdown steps are not eligible.
+	Eligible int
+	// Explored is how many eligible steps took the random branch.
+	Explored int
+	// Expected is the number of explorations epsilon predicts.
+	Expected float64
+	// Z is the binomial z-score of Explored against Expected.
+	Z float64
+	// Mismatch is set when Z is too large to be chance.
+	Mismatch bool
+}
+
+// AuditExploration checks explored out of eligible coin flips against a
+// constant epsilon.
+func AuditExploration(epsilon float64, eligible, explored int) ExplorationAudit {
+	a := ExplorationAudit{
+		Eligible: eligible,
+		Explored: explored,
+		Expected: epsilon * float64(eligible),
+	}
+	variance := float64(eligible) * epsilon * (1 - epsilon)
+	if variance == 0 {
+		// epsilon of 0 or 1 leaves no room for chance.
+		a.Mismatch = float64(explored) != a.Expected
+		return a
+	}
+	a.Z = (float64(explored) - a.Expected) / math.Sqrt(variance)
+	a.Mismatch = math.Abs(a.Z) > auditZ
+	return a
+}
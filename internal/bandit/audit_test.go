@@ -0,0 +1,42 @@
+package bandit
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAuditExploration(t *testing.T) {
+	tests := []struct {
+		name         string
+		epsilon      float64
+		eligible     int
+		explored     int
+		wantExpected float64
+		wantZ        float64
+		wantMismatch bool
+	}{
+		{name: "no coin flips", epsilon: 0.4},
+		{name: "exactly epsilon", epsilon: 0.4, eligible: 100, explored: 40, wantExpected: 40},
+		{name: "within chance", epsilon: 0.4, eligible: 100, explored: 50, wantExpected: 40, wantZ: 10 / math.Sqrt(24)},
+		{name: "too many", epsilon: 0.4, eligible: 100, explored: 60, wantExpected: 40, wantZ: 20 / math.Sqrt(24), wantMismatch: true},
+		// The bug this catches: epsilon ignored, so nothing explored.
+		{name: "never explored", epsilon: 0.4, eligible: 100, wantExpected: 40, wantZ: -40 / math.Sqrt(24), wantMismatch: true},
+		{name: "epsilon zero", eligible: 10},
+		{name: "epsilon zero but explored", eligible: 10, explored: 1, wantMismatch: true},
+		{name: "epsilon one", epsilon: 1, eligible: 10, explored: 10, wantExpected: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AuditExploration(tt.epsilon, tt.eligible, tt.explored)
+			if got.Eligible != tt.eligible || got.Explored != tt.explored {
+				t.Errorf("audit = %+v, want %d eligible and %d explored", got, tt.eligible, tt.explored)
+			}
+			if math.Abs(got.Expected-tt.wantExpected) > 1e-9 || math.Abs(got.Z-tt.wantZ) > 1e-9 {
+				t.Errorf("Expected, Z = %v, %v, want %v, %v", got.Expected, got.Z, tt.wantExpected, tt.wantZ)
+			}
+			if got.Mismatch != tt.wantMismatch {
+				t.Errorf("Mismatch = %v, want %v", got.Mismatch, tt.wantMismatch)
+			}
+		})
+	}
+}
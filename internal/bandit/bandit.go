@@ -0,0 +1,126 @@
+// Package bandit holds the epsilon-greedy bookkeeping used to pick which
+// combo of Morties to send to each planet.
+package bandit
+
+import (
+	"log/slog"
+	"math/rand/v2"
+)
+
+// Action tracks the survival rates observed for a single combo. Use NewAction,
+// NewPrior or Update to create one from outside the package.
+type Action struct {
+	avgSurvivalRate     float32
+	survivalRateHistory []float32
+}
+
+// NewAction returns an Action seeded with a single survival rate.
+func NewAction(rate float32) *Action {
+	return &Action{avgSurvivalRate: rate, survivalRateHistory: []float32{rate}}
+}
+
+// NewPrior returns an Action that has not been tried but is assumed to have
+// the given survival rate until it is. The first Update replaces the guess.
+func NewPrior(rate float32) *Action {
+	return &Action{avgSurvivalRate: rate}
+}
+
+// Tried reports whether any survival rate has been observed for the combo,
+// as opposed to it only having a prior.
+func (a *Action) Tried() bool {
+	return len(a.survivalRateHistory) > 0
+}
+
+// Tried returns the number of combos in actions with at least one observed
+// survival rate.
+func Tried(actions map[[3]int]*Action) int {
+	n := 0
+	for _, a := range actions {
+		if a.Tried() {
+			n++
+		}
+	}
+	return n
+}
+
+// AvgSurvivalRate returns the average survival rate observed for the combo.
+func (a *Action) AvgSurvivalRate() float32 {
+	return a.avgSurvivalRate
+}
+
+// Update records the survival rate observed for combo, adding the combo to
+// actions if it has not been tried before.
+func Update(actions map[[3]int]*Action, combo [3]int, rate float32) {
+	if _, ok := actions[combo]; ok {
+		actions[combo].survivalRateHistory = append(actions[combo].survivalRateHistory, rate)
+		actions[combo].avgSurvivalRate = Average(actions[combo].survivalRateHistory)
+	} else {
+		actions[combo] = NewAction(rate)
+	}
+}
+
+// ISSUE: Dead code - function never called
+// ISSUE: Function name says "Float" but operates on []int
+// ISSUE: Debug log says "FIND MAX FLOAT" but it's finding max int
+func FindMax(f []int) int {
+	slog.Debug("FIND MAX FLOAT", "length of slice", len(f))
+	if len(f) == 0 {
+		return 0
+	}
+
+	highest := f[0]
+	for _, v := range f {
+		if v > highest {
+			highest = v
+		}
+	}
+	slog.Debug("FindMaxFloat", "values", f, "highest", highest)
+	return highest
+}
+
+// FindBestSurvivalCombo returns the allowed combo not pruned by p with the
+// highest average survival rate. Every tried combo is a candidate, so a table
+// where everything has died still yields a real combo rather than [0,0,0];
+// with no candidate at all it falls back to RandomCombo with r and p. p may
+// be nil.
+func (s *Space) FindBestSurvivalCombo(r *rand.Rand, actions map[[3]int]*Action, p *Pruner) [3]int {
+	slog.Debug("FindBestSurvivalCombo")
+	var highest float32
+	var bestCombo [3]int
+	found := false
+	for i, v := range actions {
+		if !s.Allows(i) || p.Pruned(i) {
+			continue
+		}
+		if !found || v.avgSurvivalRate > highest {
+			found = true
+			highest = v.avgSurvivalRate
+			bestCombo = i
+		}
+	}
+	if !found {
+		return s.RandomCombo(r, p)
+	}
+	slog.Debug("returned combo", "bestCombo", bestCombo)
+	return bestCombo
+}
+
+func Average(f []float32) float32 {
+	if len(f) == 0 {
+		return 0
+	}
+
+	var total float32
+	// ISSUE: Redundant - count will always equal len(f)
+	// Just use len(f) instead of counting in loop
+	var count int
+	for _, v := range f {
+		count++
+		total += v
+	}
+
+	// ISSUE: count == len(f), so this is just total / float32(len(f))
+	// CRITICAL: If len(f) was 0 and check was removed, division by zero
+	return total / float32(count)
+
+}
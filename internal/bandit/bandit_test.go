@@ -0,0 +1,100 @@
+package bandit
+
+import "testing"
+
+func TestPrior(t *testing.T) {
+	seed := [3]int{2, 2, 2}
+	actions := map[[3]int]*Action{seed: NewPrior(0.1)}
+	if actions[seed].Tried() || Tried(actions) != 0 {
+		t.Fatal("a prior counts as tried")
+	}
+
+	Update(actions, seed, 0.8)
+	if !actions[seed].Tried() || Tried(actions) != 1 {
+		t.Fatal("an observed combo does not count as tried")
+	}
+	// The first observation replaces the guess instead of averaging it in.
+	if got := actions[seed].AvgSurvivalRate(); got != 0.8 {
+		t.Errorf("AvgSurvivalRate = %v, want 0.8", got)
+	}
+	Update(actions, seed, 0.4)
+	if got := actions[seed].AvgSurvivalRate(); got < 0.5999 || got > 0.6001 {
+		t.Errorf("AvgSurvivalRate = %v, want 0.6", got)
+	}
+}
+
+func TestRandomUntriedComboTriesPrior(t *testing.T) {
+	// Only 2,2,2 and one other combo are allowed, and 2,2,2 has a prior.
+	var exclude [][3]int
+	s, err := NewSpace(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range s.Combos() {
+		if c != [3]int{2, 2, 2} && c != [3]int{1, 1, 1} {
+			exclude = append(exclude, c)
+		}
+	}
+	s, err = NewSpace(nil, exclude)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := map[[3]int]*Action{{2, 2, 2}: NewPrior(0.1)}
+	Update(actions, [3]int{1, 1, 1}, 0.5)
+	for range 20 {
+		if got := s.RandomUntriedCombo(rng, actions, nil); got != [3]int{2, 2, 2} {
+			t.Fatalf("RandomUntriedCombo = %v, want the untried 2,2,2", got)
+		}
+	}
+}
+
+func TestRandomUntriedComboCoversSpace(t *testing.T) {
+	// Forced exploration tries every combo once before repeating any.
+	s, err := NewSpace(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := map[[3]int]*Action{{2, 2, 2}: NewPrior(0.1)}
+	for i := range len(s.Combos()) {
+		combo := s.RandomUntriedCombo(rng, actions, nil)
+		if a, ok := actions[combo]; ok && a.Tried() {
+			t.Fatalf("pick %d: %v was already tried", i, combo)
+		}
+		Update(actions, combo, 0.5)
+	}
+	if got := Tried(actions); got != len(s.Combos()) {
+		t.Errorf("tried %d combos, want all %d", got, len(s.Combos()))
+	}
+}
+
+func TestFindBestSurvivalComboAllZero(t *testing.T) {
+	s, err := NewSpace([]int{0}, [][3]int{{0, 1, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		actions map[[3]int]*Action
+	}{
+		{name: "empty table", actions: map[[3]int]*Action{}},
+		{name: "everything died", actions: map[[3]int]*Action{{0, 2, 1}: NewAction(0), {0, 3, 3}: NewAction(0)}},
+		// Only excluded combos have been tried, so none is a candidate.
+		{name: "only excluded combos", actions: map[[3]int]*Action{{0, 1, 1}: NewAction(0), {1, 1, 1}: NewAction(1)}},
+		{name: "zero prior", actions: map[[3]int]*Action{{2, 2, 2}: NewPrior(0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 50 {
+				got := s.FindBestSurvivalCombo(rng, tt.actions, nil)
+				if got == [3]int{} || !s.Allows(got) {
+					t.Fatalf("FindBestSurvivalCombo = %v, want an allowed combo", got)
+				}
+			}
+		})
+	}
+	// A tried combo at zero is still chosen over trying something new.
+	actions := map[[3]int]*Action{{0, 2, 1}: NewAction(0)}
+	if got := s.FindBestSurvivalCombo(rng, actions, nil); got != [3]int{0, 2, 1} {
+		t.Errorf("FindBestSurvivalCombo = %v, want the tried 0,2,1", got)
+	}
+}
@@ -0,0 +1,103 @@
+package bandit
+
+import "math"
+
+// pruneZ is the z-score of the Wilson intervals used for pruning, 95%.
+const pruneZ = 1.96
+
+// wilson returns the Wilson score interval for successes out of n trials.
+func wilson(successes, n float64) (lo, hi float64) {
+	if n == 0 {
+		return 0, 1
+	}
+	p := successes / n
+	z2 := pruneZ * pruneZ
+	denom := 1 + z2/n
+	center := (p + z2/(2*n)) / denom
+	half := pruneZ * math.Sqrt(p*(1-p)/n+z2/(4*n*n)) / denom
+	return center - half, center + half
+}
+
+// interval returns the Wilson interval of the combo's survival rate. Each
+// observed step is one trial whose outcome is the step's survival rate, so
+// the successes and the trials are both counted in steps. A combo that has
+// not been tried gets the widest interval.
+func (a *Action) interval() (lo, hi float64) {
+	n := float64(len(a.survivalRateHistory))
+	return wilson(float64(a.avgSurvivalRate)*n, n)
+}
+
+// Pruner rules out combos that are clearly worse than the best one tried so
+// far. It belongs to a single episode; the Space it prunes is left alone. A
+// nil *Pruner never prunes anything.
+type Pruner struct {
+	margin float64
+	pruned map[[3]int]bool
+}
+
+// NewPruner returns a Pruner that prunes combos whose interval lies more than
+// margin below the best combo's. A non-positive margin disables pruning.
+func NewPruner(margin float64) *Pruner {
+	if margin <= 0 {
+		return nil
+	}
+	return &Pruner{margin: margin, pruned: make(map[[3]int]bool)}
+}
+
+// Prune marks the tried combos whose interval lies more than the margin
+// below the best combo's interval as pruned. The best combo is the one with
+// the highest lower bound, so a lucky combo tried once cannot shelter the
+// rest. Combos with only a prior are neither best nor pruned.
+//
+// RandomCombo and FindBestSurvivalCombo skip pruned combos, but their
+// statistics are kept. A pruned combo is readmitted once the best combo's
+// average falls below the top of its interval. Prune returns the combos it
+// pruned and readmitted.
+func (p *Pruner) Prune(s *Space, actions map[[3]int]*Action) (pruned, readmitted [][3]int) {
+	if p == nil {
+		return nil, nil
+	}
+	var best *Action
+	var bestLo float64
+	for combo, a := range actions {
+		if !a.Tried() || !s.Allows(combo) || p.pruned[combo] {
+			continue
+		}
+		if lo, _ := a.interval(); best == nil || lo > bestLo {
+			best, bestLo = a, lo
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	bestAvg := float64(best.avgSurvivalRate)
+
+	for combo, a := range actions {
+		if a == best || !a.Tried() || !s.Allows(combo) {
+			continue
+		}
+		_, hi := a.interval()
+		switch {
+		case p.pruned[combo] && bestAvg < hi:
+			delete(p.pruned, combo)
+			readmitted = append(readmitted, combo)
+		case !p.pruned[combo] && hi+p.margin < bestLo:
+			p.pruned[combo] = true
+			pruned = append(pruned, combo)
+		}
+	}
+	return pruned, readmitted
+}
+
+// Pruned reports whether combo is currently pruned.
+func (p *Pruner) Pruned(combo [3]int) bool {
+	return p != nil && p.pruned[combo]
+}
+
+// Len returns the number of combos currently pruned.
+func (p *Pruner) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.pruned)
+}
@@ -0,0 +1,108 @@
+package bandit
+
+import "testing"
+
+// observe records rate n times for combo.
+func observe(actions map[[3]int]*Action, combo [3]int, rate float32, n int) {
+	for range n {
+		Update(actions, combo, rate)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s, err := NewSpace(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	good, bad := [3]int{1, 0, 0}, [3]int{0, 1, 0}
+	actions := make(map[[3]int]*Action)
+	observe(actions, good, 0.9, 20)
+	observe(actions, bad, 0.1, 20)
+
+	p := NewPruner(0.05)
+	pruned, readmitted := p.Prune(s, actions)
+	if len(pruned) != 1 || pruned[0] != bad || len(readmitted) != 0 {
+		t.Fatalf("Prune = %v, %v, want [%v], []", pruned, readmitted, bad)
+	}
+	if !p.Pruned(bad) || p.Pruned(good) || p.Len() != 1 {
+		t.Fatalf("Pruned(bad) = %v, Pruned(good) = %v, Len = %d", p.Pruned(bad), p.Pruned(good), p.Len())
+	}
+	for range 100 {
+		if s.RandomCombo(rng, p) == bad {
+			t.Fatal("RandomCombo returned a pruned combo")
+		}
+	}
+	if got := s.FindBestSurvivalCombo(rng, actions, p); got != good {
+		t.Errorf("FindBestSurvivalCombo = %v, want %v", got, good)
+	}
+
+	// The best combo collapses below the top of the pruned one's interval.
+	observe(actions, good, 0, 200)
+	_, readmitted = p.Prune(s, actions)
+	if len(readmitted) != 1 || readmitted[0] != bad || p.Pruned(bad) {
+		t.Fatalf("readmitted = %v, want [%v]", readmitted, bad)
+	}
+}
+
+func TestPruneLeavesSpaceAlone(t *testing.T) {
+	s, err := NewSpace(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	good, bad := [3]int{1, 0, 0}, [3]int{0, 1, 0}
+	actions := make(map[[3]int]*Action)
+	observe(actions, good, 0.9, 20)
+	observe(actions, bad, 0.1, 20)
+
+	if pruned, _ := NewPruner(0.05).Prune(s, actions); len(pruned) != 1 {
+		t.Fatalf("pruned = %v, want one combo", pruned)
+	}
+	// Another episode sharing the space starts from scratch.
+	if got := s.FindBestSurvivalCombo(rng, actions, NewPruner(0.05)); got != good {
+		t.Errorf("FindBestSurvivalCombo = %v, want %v", got, good)
+	}
+	if got := s.FindBestSurvivalCombo(rng, map[[3]int]*Action{bad: actions[bad]}, nil); got != bad {
+		t.Errorf("FindBestSurvivalCombo = %v, want %v: the shared space was pruned", got, bad)
+	}
+}
+
+func TestPruneIgnoresPrior(t *testing.T) {
+	s, err := NewSpace(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, bad := [3]int{2, 2, 2}, [3]int{0, 1, 0}
+	actions := map[[3]int]*Action{seed: NewPrior(0.9)}
+	observe(actions, bad, 0.1, 3)
+
+	p := NewPruner(0.01)
+	if pruned, _ := p.Prune(s, actions); len(pruned) != 0 {
+		t.Fatalf("pruned = %v against an untried prior", pruned)
+	}
+	if p.Pruned(seed) {
+		t.Fatal("the prior was pruned")
+	}
+}
+
+func TestPruneIntervalCountsSteps(t *testing.T) {
+	a := NewAction(0.5)
+	// One step is one trial, however many Morties it sent.
+	lo, hi := a.interval()
+	wlo, whi := wilson(0.5, 1)
+	if lo != wlo || hi != whi {
+		t.Errorf("interval = [%v, %v], want [%v, %v]", lo, hi, wlo, whi)
+	}
+}
+
+func TestNilPruner(t *testing.T) {
+	if NewPruner(0) != nil {
+		t.Error("NewPruner(0) is not nil")
+	}
+	var p *Pruner
+	if pruned, readmitted := p.Prune(nil, nil); pruned != nil || readmitted != nil {
+		t.Error("a nil Pruner pruned something")
+	}
+	if p.Pruned([3]int{1, 0, 0}) || p.Len() != 0 {
+		t.Error("a nil Pruner reports pruned combos")
+	}
+}
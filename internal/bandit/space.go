@@ -0,0 +1,211 @@
+package bandit
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxPerPlanet is the most Morties a combo sends to a single planet.
+const maxPerPlanet = 3
+
+// Space is the set of combos the solver may send. Excluded planets never
+// receive a Morty and excluded combos are never generated or picked.
+type Space struct {
+	excludedPlanets [3]bool
+	excludedCombos  map[[3]int]bool
+	combos          [][3]int
+}
+
+// NewSpace builds the combo space with the given exclusions. It fails if the
+// exclusions leave nothing to send.
+func NewSpace(excludePlanets []int, excludeCombos [][3]int) (*Space, error) {
+	s := &Space{
+		excludedCombos: make(map[[3]int]bool),
+	}
+	for _, p := range excludePlanets {
+		if p < 0 || p >= len(s.excludedPlanets) {
+			return nil, fmt.Errorf("excluded planet %d does not exist", p)
+		}
+		s.excludedPlanets[p] = true
+	}
+	for _, c := range excludeCombos {
+		s.excludedCombos[c] = true
+	}
+
+	// Every allowed planet gets 1..maxPerPlanet Morties, excluded ones 0.
+	counts := func(p int) []int {
+		if s.excludedPlanets[p] {
+			return []int{0}
+		}
+		return []int{1, 2, 3}
+	}
+	for _, a := range counts(0) {
+		for _, b := range counts(1) {
+			for _, c := range counts(2) {
+				combo := [3]int{a, b, c}
+				if combo != [3]int{} && s.Allows(combo) {
+					s.combos = append(s.combos, combo)
+				}
+			}
+		}
+	}
+	if len(s.combos) == 0 {
+		return nil, errors.New("exclusions leave no combo to send")
+	}
+	return s, nil
+}
+
+// Allows reports whether combo may be sent.
+func (s *Space) Allows(combo [3]int) bool {
+	for p, n := range combo {
+		if n > 0 && s.excludedPlanets[p] {
+			return false
+		}
+	}
+	return !s.excludedCombos[combo]
+}
+
+// Combos returns every combo in the space.
+func (s *Space) Combos() [][3]int {
+	return slices.Clone(s.combos)
+}
+
+// MaxBatch returns the most Morties any single combo sends.
+func (s *Space) MaxBatch() int {
+	most := 0
+	for _, c := range s.combos {
+		most = max(most, c[0]+c[1]+c[2])
+	}
+	return most
+}
+
+// MinBatch returns the fewest Morties any single combo sends.
+func (s *Space) MinBatch() int {
+	fewest := s.combos[0][0] + s.combos[0][1] + s.combos[0][2]
+	for _, c := range s.combos {
+		fewest = min(fewest, c[0]+c[1]+c[2])
+	}
+	return fewest
+}
+
+// Excluded returns the excluded planets and combos for logging.
+func (s *Space) Excluded() (planets []int, combos [][3]int) {
+	for p, ex := range s.excludedPlanets {
+		if ex {
+			planets = append(planets, p)
+		}
+	}
+	for c := range s.excludedCombos {
+		combos = append(combos, c)
+	}
+	return planets, combos
+}
+
+// Clamp shrinks combo so it sends no more than remaining Morties, filling
+// planets in order. If the result is not allowed, such as 1,0,0 when that
+// combo is excluded, it falls back to the allowed combo that sends the most
+// Morties without going over remaining. It reports false if there is none.
+func (s *Space) Clamp(combo [3]int, remaining int) ([3]int, bool) {
+	var clamped [3]int
+	left := remaining
+	for p, n := range combo {
+		clamped[p] = min(n, left)
+		left -= clamped[p]
+	}
+	if clamped != [3]int{} && s.Allows(clamped) {
+		return clamped, true
+	}
+
+	// Counting down keeps to filling planets in order among equal batches.
+	var best [3]int
+	for a := maxPerPlanet; a >= 0; a-- {
+		for b := maxPerPlanet; b >= 0; b-- {
+			for c := maxPerPlanet; c >= 0; c-- {
+				candidate := [3]int{a, b, c}
+				n := a + b + c
+				if n > remaining || n <= best[0]+best[1]+best[2] || !s.Allows(candidate) {
+					continue
+				}
+				best = candidate
+			}
+		}
+	}
+	return best, best != [3]int{}
+}
+
+// ParseCombo parses a combo written as "3,0,1".
+func ParseCombo(str string) ([3]int, error) {
+	parts := strings.Split(str, ",")
+	if len(parts) != 3 {
+		return [3]int{}, fmt.Errorf("combo %q must have three comma separated counts", str)
+	}
+	var combo [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 || n > maxPerPlanet {
+			return [3]int{}, fmt.Errorf("combo %q: count %q must be between 0 and %d", str, part, maxPerPlanet)
+		}
+		combo[i] = n
+	}
+	return combo, nil
+}
+
+// RandomCombo returns a combo from the space picked with r, skipping the ones
+// p has pruned unless that leaves none. p may be nil.
+func (s *Space) RandomCombo(r *rand.Rand, p *Pruner) [3]int {
+	if p.Len() == 0 {
+		return s.combos[r.IntN(len(s.combos))]
+	}
+	var active [][3]int
+	for _, combo := range s.combos {
+		if !p.Pruned(combo) {
+			active = append(active, combo)
+		}
+	}
+	if len(active) == 0 {
+		return s.combos[r.IntN(len(s.combos))]
+	}
+	return active[r.IntN(len(active))]
+}
+
+// RandomComboUpTo is RandomCombo among the combos that send at most n
+// Morties. It reports false if no combo sends so few.
+func (s *Space) RandomComboUpTo(r *rand.Rand, n int, p *Pruner) ([3]int, bool) {
+	var fits, active [][3]int
+	for _, combo := range s.combos {
+		if combo[0]+combo[1]+combo[2] > n {
+			continue
+		}
+		fits = append(fits, combo)
+		if !p.Pruned(combo) {
+			active = append(active, combo)
+		}
+	}
+	if len(active) == 0 {
+		active = fits
+	}
+	if len(active) == 0 {
+		return [3]int{}, false
+	}
+	return active[r.IntN(len(active))], true
+}
+
+// RandomUntriedCombo picks a combo with no observations in actions yet with
+// r; one with only a prior counts as untried. If every combo has been tried
+// it falls back to RandomCombo with p.
+func (s *Space) RandomUntriedCombo(r *rand.Rand, actions map[[3]int]*Action, p *Pruner) [3]int {
+	var untried [][3]int
+	for _, combo := range s.combos {
+		if a, ok := actions[combo]; !ok || !a.Tried() {
+			untried = append(untried, combo)
+		}
+	}
+	if len(untried) == 0 {
+		return s.RandomCombo(r, p)
+	}
+	return untried[r.IntN(len(untried))]
+}
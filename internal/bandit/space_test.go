@@ -0,0 +1,113 @@
+package bandit
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// rng makes the tests' random choices repeatable.
+var rng = rand.New(rand.NewPCG(1, 2))
+
+func TestClamp(t *testing.T) {
+	tests := []struct {
+		name           string
+		excludePlanets []int
+		excludeCombos  [][3]int
+		combo          [3]int
+		remaining      int
+		want           [3]int
+		wantOK         bool
+	}{
+		{name: "fits", combo: [3]int{3, 3, 3}, remaining: 20, want: [3]int{3, 3, 3}, wantOK: true},
+		{name: "fill in order", combo: [3]int{3, 3, 3}, remaining: 5, want: [3]int{3, 2, 0}, wantOK: true},
+		{name: "one left", combo: [3]int{1, 2, 3}, remaining: 1, want: [3]int{1, 0, 0}, wantOK: true},
+		{
+			name:          "excluded combo after clamping",
+			excludeCombos: [][3]int{{1, 0, 0}},
+			combo:         [3]int{3, 3, 3},
+			remaining:     1,
+			want:          [3]int{0, 1, 0},
+			wantOK:        true,
+		},
+		{
+			name:           "excluded planet left empty",
+			excludePlanets: []int{0},
+			combo:          [3]int{0, 3, 3},
+			remaining:      4,
+			want:           [3]int{0, 3, 1},
+			wantOK:         true,
+		},
+		{
+			name:          "nothing fits",
+			excludeCombos: [][3]int{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+			combo:         [3]int{3, 3, 3},
+			remaining:     1,
+			wantOK:        false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSpace(tt.excludePlanets, tt.excludeCombos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := s.Clamp(tt.combo, tt.remaining)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Clamp(%v, %d) = %v, %v, want %v, %v", tt.combo, tt.remaining, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestClampOnlyReturnsAllowedCombos(t *testing.T) {
+	s, err := NewSpace([]int{2}, [][3]int{{1, 0, 0}, {2, 1, 0}, {3, 3, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, combo := range s.Combos() {
+		for remaining := 1; remaining <= 10; remaining++ {
+			got, ok := s.Clamp(combo, remaining)
+			if !ok {
+				continue
+			}
+			if !s.Allows(got) || batch(got) > remaining || batch(got) == 0 {
+				t.Errorf("Clamp(%v, %d) = %v, not an allowed combo of at most %d", combo, remaining, got, remaining)
+			}
+		}
+	}
+}
+
+func batch(combo [3]int) int {
+	return combo[0] + combo[1] + combo[2]
+}
+
+func TestRandomComboUpTo(t *testing.T) {
+	s, err := NewSpace(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.MinBatch(); got != 3 {
+		t.Errorf("MinBatch = %d, want 3", got)
+	}
+	if _, ok := s.RandomComboUpTo(rng, s.MinBatch()-1, nil); ok {
+		t.Error("RandomComboUpTo found a combo below MinBatch")
+	}
+	for range 100 {
+		got, ok := s.RandomComboUpTo(rng, 4, nil)
+		if !ok || batch(got) > 4 || !s.Allows(got) {
+			t.Fatalf("RandomComboUpTo(4) = %v, %v", got, ok)
+		}
+	}
+
+	// Pruning is skipped unless it leaves nothing that fits.
+	p := NewPruner(0.05)
+	p.pruned[[3]int{1, 1, 1}] = true
+	if got, ok := s.RandomComboUpTo(rng, 3, p); !ok || got != [3]int{1, 1, 1} {
+		t.Errorf("RandomComboUpTo(3) with 1,1,1 pruned = %v, %v, want it anyway", got, ok)
+	}
+	for range 100 {
+		if got, _ := s.RandomComboUpTo(rng, 4, p); got == [3]int{1, 1, 1} {
+			t.Fatal("RandomComboUpTo returned a pruned combo")
+		}
+	}
+}
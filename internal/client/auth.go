@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// AuthProvider supplies the Authorization header. It is asked once per
+// request, so a rotated token is picked up without restarting. When the API
+// answers 401 it is asked again, and the request is repeated once if the
+// header has changed.
+type AuthProvider interface {
+	Header(ctx context.Context) (string, error)
+}
+
+// WithAuthProvider takes the Authorization header from p instead of the
+// fixed value passed to NewClient.
+func WithAuthProvider(p AuthProvider) Option {
+	return func(c *Client) {
+		c.auth = p
+	}
+}
+
+// StaticAuth is a header that never changes.
+type StaticAuth string
+
+func (a StaticAuth) Header(context.Context) (string, error) {
+	return string(a), nil
+}
+
+// EnvAuth reads the header from the named environment variable on every
+// request.
+type EnvAuth string
+
+func (a EnvAuth) Header(context.Context) (string, error) {
+	v := os.Getenv(string(a))
+	if v == "" {
+		return "", fmt.Errorf("%s is not set", string(a))
+	}
+	return v, nil
+}
+
+// FileAuth reads the header from the named file on every request, trimming
+// surrounding whitespace, so a token rotated on disk is used at once.
+type FileAuth string
+
+func (a FileAuth) Header(context.Context) (string, error) {
+	b, err := os.ReadFile(string(a))
+	if err != nil {
+		return "", err
+	}
+	v := strings.TrimSpace(string(b))
+	if v == "" {
+		return "", fmt.Errorf("%s is empty", string(a))
+	}
+	return v, nil
+}
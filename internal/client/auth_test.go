@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// rotatingServer accepts only its current token. rotate swaps in next and
+// calls onRotate, as if the token changed while a request was in flight.
+type rotatingServer struct {
+	mu       sync.Mutex
+	current  string
+	seen     []string
+	rotateTo string
+	onRotate func(string)
+}
+
+func (s *rotatingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	got := r.Header.Get("Authorization")
+	s.seen = append(s.seen, got)
+	if s.rotateTo != "" {
+		s.current, s.rotateTo = s.rotateTo, ""
+		s.onRotate(s.current)
+	}
+	if got != s.current {
+		writeJSON(w, http.StatusUnauthorized, `{"detail":"invalid token"}`)
+		return
+	}
+	writeJSON(w, http.StatusOK, statusBody)
+}
+
+func (s *rotatingServer) rotate(next string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rotateTo = next
+}
+
+func TestAuthRotatesMidRun(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T) (AuthProvider, func(string))
+	}{
+		{
+			name: "file",
+			setup: func(t *testing.T) (AuthProvider, func(string)) {
+				path := filepath.Join(t.TempDir(), "token")
+				write := func(v string) {
+					if err := os.WriteFile(path, []byte(v+"\n"), 0o600); err != nil {
+						t.Error(err)
+					}
+				}
+				write("old")
+				return FileAuth(path), write
+			},
+		},
+		{
+			name: "env",
+			setup: func(t *testing.T) (AuthProvider, func(string)) {
+				t.Setenv("MORTY_TEST_AUTH", "old")
+				return EnvAuth("MORTY_TEST_AUTH"), func(v string) { os.Setenv("MORTY_TEST_AUTH", v) }
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, set := tt.setup(t)
+			srv := &rotatingServer{current: "old", onRotate: set}
+			c := newTestClient(t, srv, WithAuthProvider(provider))
+			ctx := context.Background()
+
+			if _, err := c.Status(ctx); err != nil {
+				t.Fatalf("first Status: %v", err)
+			}
+			srv.rotate("new")
+			if _, err := c.Status(ctx); err != nil {
+				t.Fatalf("Status after rotation: %v", err)
+			}
+			if _, err := c.Status(ctx); err != nil {
+				t.Fatalf("Status with the new token: %v", err)
+			}
+			want := []string{"old", "old", "new", "new"}
+			if len(srv.seen) != len(want) {
+				t.Fatalf("server saw tokens %q, want %q", srv.seen, want)
+			}
+			for i := range want {
+				if srv.seen[i] != want[i] {
+					t.Fatalf("server saw tokens %q, want %q", srv.seen, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthStaticNotRepeated(t *testing.T) {
+	srv := &rotatingServer{current: "other"}
+	c := newTestClient(t, srv)
+	_, err := c.Status(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.Unauthorized() {
+		t.Fatalf("err = %v, want a 401 *APIError", err)
+	}
+	// The header cannot change, so the request is not repeated.
+	if len(srv.seen) != 1 {
+		t.Errorf("server saw %d requests, want 1", len(srv.seen))
+	}
+}
+
+func TestAuthProviderErrors(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(empty, []byte("  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MORTY_TEST_UNSET", "")
+	for _, p := range []AuthProvider{
+		FileAuth(filepath.Join(t.TempDir(), "missing")),
+		FileAuth(empty),
+		EnvAuth("MORTY_TEST_UNSET"),
+	} {
+		if _, err := p.Header(context.Background()); err == nil {
+			t.Errorf("%#v: Header succeeded", p)
+		}
+	}
+}
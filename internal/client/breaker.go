@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Breaker states.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// ErrBreakerOpen is returned for a request made while the circuit breaker is
+// open once it has given up probing.
+var ErrBreakerOpen = errors.New("circuit breaker open, API not answering")
+
+// WithBreaker stops sending requests after threshold consecutive failures.
+// While open, the client waits cooldown, probes the status endpoint and only
+// lets requests through again once a probe succeeds. After maxProbes failed
+// probes in a row it gives up and fails the request with ErrBreakerOpen; zero
+// means it never gives up. A threshold of zero disables the breaker.
+func WithBreaker(threshold int, cooldown time.Duration, maxProbes int) Option {
+	return func(c *Client) {
+		if threshold > 0 {
+			c.breaker = &breaker{threshold: threshold, cooldown: cooldown, maxProbes: maxProbes, state: BreakerClosed}
+		}
+	}
+}
+
+// BreakerStats summarizes what the circuit breaker did during a run.
+type BreakerStats struct {
+	State string
+	// Trips is how many times the breaker opened.
+	Trips int
+	// Open is the total time spent open or half-open.
+	Open time.Duration
+}
+
+// BreakerStats returns the breaker's state and history. It is the zero value
+// when the breaker is disabled.
+func (c *Client) BreakerStats() BreakerStats {
+	b := c.breaker
+	if b == nil {
+		return BreakerStats{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BreakerStats{State: b.state, Trips: b.trips, Open: b.open}
+}
+
+// breaker counts consecutive failed requests. A nil *breaker never opens.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	maxProbes int
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	trips    int
+	open     time.Duration
+}
+
+// record counts err as a failure if the API could not be reached or
+// answered with a server error.
+func (b *breaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var re retryableError
+	if err == nil || !errors.As(err, &re) || re.rejected {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == BreakerClosed && b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.trips++
+		slog.Warn("circuit breaker opened", "consecutive_failures", b.failures, "cooldown", b.cooldown)
+	}
+}
+
+// wait blocks while the breaker is open, probing after every cooldown until
+// a probe succeeds, maxProbes have failed or ctx is done. A probe refused by
+// the request cap ends the wait at once.
+func (b *breaker) wait(ctx context.Context, probe func(context.Context) error) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	open := b.state != BreakerClosed
+	b.mu.Unlock()
+	if !open {
+		return nil
+	}
+
+	start := time.Now()
+	defer func() {
+		b.mu.Lock()
+		b.open += time.Since(start)
+		b.mu.Unlock()
+	}()
+	for n := 1; ; n++ {
+		if err := sleep(ctx, b.cooldown); err != nil {
+			return err
+		}
+		b.transition(BreakerHalfOpen)
+		err := probe(ctx)
+		if err == nil {
+			b.mu.Lock()
+			b.failures = 0
+			b.mu.Unlock()
+			b.transition(BreakerClosed)
+			return nil
+		}
+		b.transition(BreakerOpen)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, ErrRequestCap) {
+			return err
+		}
+		slog.Warn("circuit breaker probe failed", "probe", n, "max_probes", b.maxProbes, "error", err)
+		if b.maxProbes > 0 && n >= b.maxProbes {
+			return fmt.Errorf("%w after %d probes: %w", ErrBreakerOpen, n, err)
+		}
+	}
+}
+
+func (b *breaker) transition(state string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != state {
+		slog.Info("circuit breaker state changed", "from", b.state, "to", state)
+		b.state = state
+	}
+}
+
+// probe checks whether the API is answering again with a single status
+// request, bypassing the retry logic.
+func (c *Client) probe(ctx context.Context) error {
+	header, err := c.auth.Header(ctx)
+	if err != nil {
+		return fmt.Errorf("getting auth header: %w", err)
+	}
+	_, err = doOnce[Status](ctx, c, rand.Text(), header, http.MethodGet, c.statusURL, nil)
+	return err
+}
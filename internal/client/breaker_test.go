@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failing answers 503 to the first n requests and a status after that.
+func failing(n int64, hits *atomic.Int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= n {
+			writeJSON(w, http.StatusServiceUnavailable, `{}`)
+			return
+		}
+		writeJSON(w, http.StatusOK, statusBody)
+	}
+}
+
+func TestBreakerClosesAfterProbe(t *testing.T) {
+	var hits atomic.Int64
+	// Two failures open the breaker, the first probe fails, the second
+	// succeeds and the request goes through.
+	c := newTestClient(t, failing(3, &hits),
+		WithRetry(5, time.Millisecond, false),
+		WithBreaker(2, time.Millisecond, 3),
+	)
+	if _, err := c.Status(context.Background()); err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	stats := c.BreakerStats()
+	if stats.State != BreakerClosed || stats.Trips != 1 {
+		t.Errorf("stats = %+v, want closed after 1 trip", stats)
+	}
+	if got := hits.Load(); got != 5 {
+		t.Errorf("server saw %d requests, want 5", got)
+	}
+}
+
+func TestBreakerGivesUp(t *testing.T) {
+	var hits atomic.Int64
+	c := newTestClient(t, failing(1<<30, &hits),
+		WithRetry(10, time.Millisecond, false),
+		WithBreaker(2, time.Millisecond, 3),
+	)
+	_, err := c.Status(context.Background())
+	if !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("err = %v, want ErrBreakerOpen", err)
+	}
+	if stats := c.BreakerStats(); stats.State != BreakerOpen {
+		t.Errorf("state = %s, want open", stats.State)
+	}
+	// Two failures and three probes.
+	if got := hits.Load(); got != 5 {
+		t.Errorf("server saw %d requests, want 5", got)
+	}
+}
+
+func TestBreakerProbesRespectRequestCap(t *testing.T) {
+	var hits atomic.Int64
+	c := newTestClient(t, failing(1<<30, &hits),
+		WithRetry(100, time.Millisecond, false),
+		WithBreaker(2, time.Millisecond, 0),
+		WithMaxRequests(10),
+	)
+	_, err := c.Status(context.Background())
+	if !errors.Is(err, ErrRequestCap) {
+		t.Fatalf("err = %v, want ErrRequestCap", err)
+	}
+	if got := hits.Load(); got != 10 {
+		t.Errorf("server saw %d requests, want 10", got)
+	}
+	if got := c.Requests(); got != 10 {
+		t.Errorf("Requests() = %d, want 10", got)
+	}
+}
+
+func TestBreakerStopsOnCancel(t *testing.T) {
+	var hits atomic.Int64
+	c := newTestClient(t, failing(1<<30, &hits),
+		WithRetry(10, time.Millisecond, false),
+		WithBreaker(1, time.Hour, 0),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Status(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,383 @@
+// Package client talks to the Sphinx HQ Morty challenge API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBaseURL is the live challenge server, https://challenge.sphinxhq.com/.
+const DefaultBaseURL = "https://challenge.sphinxhq.com"
+
+// DefaultTimeout bounds each HTTP request of a Client built without an
+// http.Client.
+const DefaultTimeout = 30 * time.Second
+
+const (
+	startEndpoint  = "/api/mortys/start/"
+	portalEndpoint = "/api/mortys/portal/"
+	statusEndpoint = "/api/mortys/status/"
+)
+
+// userAgent identifies this client to the challenge operators.
+var userAgent = "savemorty/" + buildVersion()
+
+// buildVersion returns the module version, or the VCS revision for a
+// development build.
+func buildVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if v := bi.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	for _, s := range bi.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value[:min(len(s.Value), 12)]
+		}
+	}
+	return "devel"
+}
+
+// bodyPool recycles the buffers SendMorty request bodies are encoded into.
+var bodyPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// Doer sends an HTTP request. *http.Client is one; so are most retrying or
+// instrumented clients.
+type Doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Client calls the challenge API at a fixed base URL. The Authorization
+// header comes from its AuthProvider, which is asked on every request; it is
+// the header passed to NewClient unless WithAuthProvider replaces it.
+type Client struct {
+	httpClient *http.Client
+	// doer sends every request. It is httpClient unless WithDoer was used.
+	doer Doer
+	auth AuthProvider
+
+	// Endpoint URLs are parsed and joined once; each request gets a copy.
+	startURL  *url.URL
+	portalURL *url.URL
+	statusURL *url.URL
+	// baseErr is why the base URL did not parse. Every request fails with it.
+	baseErr error
+
+	// requests counts every request attempted against the API.
+	requests atomic.Int64
+	// maxRequests refuses any attempt past it. Zero means no cap.
+	maxRequests int64
+
+	maxRetries  int
+	retryBase   time.Duration
+	retryPortal bool
+
+	breaker *breaker
+	limiter *limiter
+
+	strictDecode bool
+}
+
+// ParseBaseURL validates a base URL for NewClient. It must be an absolute
+// http or https URL; a trailing slash is dropped.
+func ParseBaseURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid base URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid base URL %q: no host", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid base URL %q: must not have a query or fragment", raw)
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+// NewClient returns a Client for the API at baseURL that sends authHeader as
+// the Authorization header. baseURL should have been checked with
+// ParseBaseURL. A nil httpClient means a new http.Client with
+// DefaultTimeout, so a stalled server cannot hang a request forever.
+func NewClient(baseURL, authHeader string, httpClient *http.Client, opts ...Option) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	c := &Client{
+		httpClient: httpClient,
+		auth:       StaticAuth(authHeader),
+	}
+	c.startURL, c.baseErr = endpointURL(baseURL, startEndpoint)
+	if c.baseErr == nil {
+		c.portalURL, _ = endpointURL(baseURL, portalEndpoint)
+		c.statusURL, _ = endpointURL(baseURL, statusEndpoint)
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.doer == nil {
+		c.doer = c.httpClient
+	}
+	return c
+}
+
+// WithDoer sends every request through d instead of the http.Client passed
+// to NewClient. WithTransport has no effect on d.
+func WithDoer(d Doer) Option {
+	return func(c *Client) {
+		c.doer = d
+	}
+}
+
+// WithTransport sends every request through rt, for proxies or
+// instrumentation. The http.Client passed to NewClient is copied, not
+// modified.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		hc := *c.httpClient
+		hc.Transport = rt
+		c.httpClient = &hc
+	}
+}
+
+// maxDrain is how much of an unread response body is read off before
+// closing it. Anything longer is cheaper to drop with the connection.
+const maxDrain = 64 << 10
+
+// drainAndClose reads what is left of body and closes it, so the connection
+// goes back to the pool instead of being torn down.
+func drainAndClose(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, maxDrain))
+	body.Close()
+}
+
+// endpointURL joins an endpoint path onto the base URL and parses the result,
+// once, for newRequest to copy.
+func endpointURL(base, endpoint string) (*url.URL, error) {
+	joined, err := url.JoinPath(base, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return url.Parse(joined)
+}
+
+// newRequest builds a request for a copy of u, so u is neither parsed again
+// nor shared with anything that might modify the request. A nil body sends
+// no body.
+func newRequest(ctx context.Context, method string, u *url.URL, body []byte) (*http.Request, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	// An empty URL costs next to nothing to parse, and gives the request a
+	// URL of its own to copy u into.
+	req, err := http.NewRequestWithContext(ctx, method, "", r)
+	if err != nil {
+		return nil, err
+	}
+	*req.URL = *u
+	req.Host = u.Host
+	return req, nil
+}
+
+// ErrRequestCap is returned, without sending anything, for a request that
+// would take the client past the cap set with WithMaxRequests.
+var ErrRequestCap = errors.New("request cap reached")
+
+// WithMaxRequests refuses to attempt more than n requests, counting every
+// retry and breaker probe, with ErrRequestCap. Zero means no cap.
+func WithMaxRequests(n int64) Option {
+	return func(c *Client) {
+		c.maxRequests = max(0, n)
+	}
+}
+
+// Requests returns the number of requests attempted so far.
+func (c *Client) Requests() int64 {
+	return c.requests.Load()
+}
+
+// take counts a request about to be attempted, or reports false if the cap
+// has been reached.
+func (c *Client) take() bool {
+	for {
+		n := c.requests.Load()
+		if c.maxRequests > 0 && n >= c.maxRequests {
+			return false
+		}
+		if c.requests.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// IsTimeout reports whether err is a request that ran out of time, either
+// from the http.Client timeout or a context deadline.
+func IsTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// SendMorties sends combo[i] Morties through the portal to planet i, skipping
+// planets with none. If a send fails, or ctx is cancelled partway through,
+// the result holds the planets sent so far and the remaining ones are not
+// sent to. A failed send is returned as a *PlanetError.
+func (c *Client) SendMorties(ctx context.Context, combo [3]int) (SendResult, error) {
+	result := SendResult{Combo: combo}
+	for i, v := range combo {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		// The API rejects empty sends, and there is nothing to learn.
+		if v == 0 {
+			continue
+		}
+		req := SendMorty{Planet: i, MortyCount: v}
+		portal, err := c.sendPlanet(ctx, req)
+		if err != nil {
+			return result, &PlanetError{Planet: i, Err: err}
+		}
+		result.Planets = append(result.Planets, PlanetResult{Request: req, Portal: portal})
+	}
+	return result, nil
+}
+
+// sendPlanet makes a single portal request, retrying it only if the client
+// was configured to retry portal requests.
+func (c *Client) sendPlanet(ctx context.Context, req SendMorty) (Portal, error) {
+	buf := bodyPool.Get().(*bytes.Buffer)
+	defer bodyPool.Put(buf)
+	buf.Reset()
+	if err := json.NewEncoder(buf).Encode(req); err != nil {
+		return Portal{}, fmt.Errorf("encoding request: %w", err)
+	}
+
+	return do[Portal](ctx, c, http.MethodPost, c.portalURL, buf.Bytes(), c.retryPortal)
+}
+
+// StartEpisode starts a new episode and returns its initial status.
+func (c *Client) StartEpisode(ctx context.Context) (Status, error) {
+	slog.Debug("Starting Episode")
+	return do[Status](ctx, c, http.MethodPost, c.startURL, nil, true)
+}
+
+// Status returns the status of the current episode.
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	slog.Debug("Episode Status")
+	return do[Status](ctx, c, http.MethodGet, c.statusURL, nil, true)
+}
+
+// do makes a request and decodes its JSON answer into a T, retrying it as
+// the client was configured to. If retryable is false, only requests the
+// server rejected without acting on them are retried. Every request to the
+// API goes through here.
+//
+// Each request gets an ID, sent as X-Request-ID on every attempt and logged
+// with it, so a failure can be matched to the server's logs.
+func do[T any](ctx context.Context, c *Client, method string, u *url.URL, body []byte, retryable bool) (T, error) {
+	id := rand.Text()
+	header, err := c.auth.Header(ctx)
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("getting auth header: %w", err)
+	}
+	v, err := doRetry[T](ctx, c, id, header, method, u, body, retryable)
+
+	// A 401 may mean the token rotated; a rejected request did nothing, so
+	// it is safe to repeat once with a fresh header.
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+		fresh, ferr := c.auth.Header(ctx)
+		if ferr == nil && fresh != header {
+			slog.Warn("credential rejected, retrying with a refreshed one", "request_id", id)
+			return doRetry[T](ctx, c, id, fresh, method, u, body, retryable)
+		}
+	}
+	return v, err
+}
+
+// doRetry makes a request with the given Authorization header, retrying it
+// as do describes.
+func doRetry[T any](ctx context.Context, c *Client, id, header, method string, u *url.URL, body []byte, retryable bool) (T, error) {
+	var v T
+	err := c.retry(ctx, id, retryable, func() error {
+		var err error
+		v, err = doOnce[T](ctx, c, id, header, method, u, body)
+		return err
+	})
+	return v, err
+}
+
+// doOnce makes one attempt at a request. A nil body sends no body and no
+// Content-Type.
+func doOnce[T any](ctx context.Context, c *Client, id, header, method string, u *url.URL, body []byte) (T, error) {
+	var zero T
+	if c.baseErr != nil {
+		return zero, fmt.Errorf("%w: invalid base URL: %w", ErrRequest, c.baseErr)
+	}
+	req, err := newRequest(ctx, method, u, body)
+	if err != nil {
+		return zero, fmt.Errorf("%w: %w", ErrRequest, err)
+	}
+	req.Header.Set("Authorization", header)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Request-ID", id)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if err := c.limiter.wait(ctx); err != nil {
+		return zero, err
+	}
+	if !c.take() {
+		return zero, fmt.Errorf("%w: %d requests made", ErrRequestCap, c.maxRequests)
+	}
+	slog.Debug("sending request", "method", method, "url", u, "request_id", id)
+	res, err := c.doer.Do(req)
+	if err != nil {
+		return zero, retryableError{err: fmt.Errorf("%w %s: %w", ErrTransport, id, err)}
+	}
+	defer drainAndClose(res.Body)
+	if err := checkResponse(res); err != nil {
+		return zero, err
+	}
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return zero, fmt.Errorf("%w %s: reading response body: %w", ErrTransport, id, err)
+	}
+	// Whatever sent a page instead of an answer may be gone on the next
+	// try, but it is unknown whether the API acted on the request.
+	if err := checkJSON(res, b); err != nil {
+		return zero, retryableError{err: err}
+	}
+	if err := checkErrorBody(res, b); err != nil {
+		return zero, err
+	}
+	v, err := decode[T](b, c.strictDecode)
+	if err != nil {
+		return zero, &DecodeError{Endpoint: res.Request.URL.Path, RequestID: id, Body: string(b), Err: err}
+	}
+	slog.Debug("decoded response", "url", u, "status", res.StatusCode, "request_id", id)
+	return v, nil
+}
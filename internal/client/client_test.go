@@ -0,0 +1,446 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// statusBody is a status answer for an episode in progress.
+const statusBody = `{"morties_in_citadel":1000,"morties_on_planet_jessica":0,"morties_lost":0,"steps_taken":0,"status_message":""}`
+
+// newTestClient starts a server running h and returns a client for it.
+func newTestClient(t *testing.T, h http.Handler, opts ...Option) *Client {
+	t.Helper()
+	return NewClient(newTestServer(t, h), "token", nil, opts...)
+}
+
+// newTestServer starts a server running h and returns its base URL.
+func newTestServer(t *testing.T, h http.Handler) string {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	base, err := ParseBaseURL(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base
+}
+
+// writeJSON answers with body as JSON.
+func writeJSON(w http.ResponseWriter, code int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write([]byte(body))
+}
+
+// portalServer answers each portal request with whether a Morty sent to that
+// planet survives, and fails requests to the planets in fail.
+func portalServer(t *testing.T, survives [3]bool, fail map[int]bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req SendMorty
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if req.MortyCount == 0 {
+			t.Errorf("request for planet %d sends no Morties", req.Planet)
+		}
+		if fail[req.Planet] {
+			writeJSON(w, http.StatusBadRequest, `{"detail":"bad planet"}`)
+			return
+		}
+		writeJSON(w, http.StatusOK, fmt.Sprintf(
+			`{"morties_sent":%d,"survived":%t,"morties_in_citadel":900,"morties_on_planet_jessica":50,"morties_lost":50,"steps_taken":3}`,
+			req.MortyCount, survives[req.Planet]))
+	}
+}
+
+func TestSendMorties(t *testing.T) {
+	tests := []struct {
+		name     string
+		combo    [3]int
+		survives [3]bool
+		fail     map[int]bool
+		// want is the planets sent to and whether each survived.
+		want       []int
+		wantAlive  []bool
+		wantPlanet int // planet of the *PlanetError, or -1 for none
+	}{
+		{
+			name:       "all survive",
+			combo:      [3]int{1, 2, 3},
+			survives:   [3]bool{true, true, true},
+			want:       []int{0, 1, 2},
+			wantAlive:  []bool{true, true, true},
+			wantPlanet: -1,
+		},
+		{
+			name:       "mixed",
+			combo:      [3]int{3, 1, 2},
+			survives:   [3]bool{true, false, true},
+			want:       []int{0, 1, 2},
+			wantAlive:  []bool{true, false, true},
+			wantPlanet: -1,
+		},
+		{
+			name:       "empty planets skipped",
+			combo:      [3]int{0, 2, 0},
+			survives:   [3]bool{true, false, true},
+			want:       []int{1},
+			wantAlive:  []bool{false},
+			wantPlanet: -1,
+		},
+		{
+			name:       "second planet fails",
+			combo:      [3]int{1, 1, 1},
+			survives:   [3]bool{false, true, true},
+			fail:       map[int]bool{1: true},
+			want:       []int{0},
+			wantAlive:  []bool{false},
+			wantPlanet: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, portalServer(t, tt.survives, tt.fail))
+			got, err := c.SendMorties(context.Background(), tt.combo)
+			var planetErr *PlanetError
+			switch {
+			case tt.wantPlanet < 0 && err != nil:
+				t.Fatalf("SendMorties: %v", err)
+			case tt.wantPlanet >= 0 && (!errors.As(err, &planetErr) || planetErr.Planet != tt.wantPlanet):
+				t.Fatalf("err = %v, want a *PlanetError for planet %d", err, tt.wantPlanet)
+			}
+			if got.Combo != tt.combo {
+				t.Errorf("Combo = %v, want %v", got.Combo, tt.combo)
+			}
+			if len(got.Planets) != len(tt.want) {
+				t.Fatalf("got %d planet results, want %d", len(got.Planets), len(tt.want))
+			}
+			for i, p := range got.Planets {
+				if p.Request.Planet != tt.want[i] || p.Request.MortyCount != tt.combo[tt.want[i]] {
+					t.Errorf("result %d request = %+v, want planet %d with %d Morties", i, p.Request, tt.want[i], tt.combo[tt.want[i]])
+				}
+				if p.Portal.Survived != tt.wantAlive[i] || int(p.Portal.MortiesSent) != p.Request.MortyCount {
+					t.Errorf("result %d portal = %+v, want survived %t", i, p.Portal, tt.wantAlive[i])
+				}
+			}
+		})
+	}
+}
+
+// sleepy holds every request until the client gives up on it or a long
+// time has passed, counting the requests it saw.
+func sleepy(hits *atomic.Int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		// The server only notices the client going away once the body
+		// has been read.
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+		writeJSON(w, http.StatusOK, statusBody)
+	}
+}
+
+func TestCancelWhileServerSleeps(t *testing.T) {
+	var hits atomic.Int64
+	c := newTestClient(t, sleepy(&hits), WithRetry(3, time.Millisecond, true))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := c.SendMorties(ctx, [3]int{1, 1, 1})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("SendMorties took %s to return after cancel", elapsed)
+	}
+	// The remaining planets are abandoned, not sent, and nothing is retried.
+	if got := hits.Load(); got != 1 {
+		t.Errorf("server saw %d requests, want 1", got)
+	}
+}
+
+func TestHTTPTimeout(t *testing.T) {
+	var hits atomic.Int64
+	base := newTestServer(t, sleepy(&hits))
+	c := NewClient(base, "token", &http.Client{Timeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	_, err := c.Status(context.Background())
+	if err == nil {
+		t.Fatal("Status succeeded against a hung server")
+	}
+	if !IsTimeout(err) || !errors.Is(err, ErrTransport) {
+		t.Errorf("err = %v, want a transport timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Status took %s to time out", elapsed)
+	}
+}
+
+func TestNilHTTPClientHasTimeout(t *testing.T) {
+	c := NewClient("http://example.invalid", "token", nil)
+	if c.httpClient == http.DefaultClient {
+		t.Fatal("nil httpClient shares http.DefaultClient")
+	}
+	if got := c.httpClient.Timeout; got != DefaultTimeout {
+		t.Errorf("Timeout = %s, want %s", got, DefaultTimeout)
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	type seen struct {
+		path, auth, userAgent, requestID, contentType string
+	}
+	var mu sync.Mutex
+	var got []seen
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, seen{
+			path:        r.URL.Path,
+			auth:        r.Header.Get("Authorization"),
+			userAgent:   r.Header.Get("User-Agent"),
+			requestID:   r.Header.Get("X-Request-ID"),
+			contentType: r.Header.Get("Content-Type"),
+		})
+		mu.Unlock()
+		if r.URL.Path == portalEndpoint {
+			writeJSON(w, http.StatusOK, `{"morties_sent":1,"survived":true,"morties_in_citadel":1,"morties_on_planet_jessica":1,"morties_lost":0,"steps_taken":1}`)
+			return
+		}
+		writeJSON(w, http.StatusOK, statusBody)
+	}))
+
+	ctx := context.Background()
+	if _, err := c.StartEpisode(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SendMorties(ctx, [3]int{1, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Status(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	wantPaths := []string{startEndpoint, portalEndpoint, statusEndpoint}
+	if len(got) != len(wantPaths) {
+		t.Fatalf("server saw %d requests, want %d", len(got), len(wantPaths))
+	}
+	ids := map[string]bool{}
+	for i, s := range got {
+		if s.path != wantPaths[i] {
+			t.Errorf("request %d went to %s, want %s", i, s.path, wantPaths[i])
+		}
+		if s.auth != "token" {
+			t.Errorf("%s: Authorization = %q, want %q", s.path, s.auth, "token")
+		}
+		if !strings.HasPrefix(s.userAgent, "savemorty/") || s.userAgent != userAgent {
+			t.Errorf("%s: User-Agent = %q, want %q", s.path, s.userAgent, userAgent)
+		}
+		if s.requestID == "" || ids[s.requestID] {
+			t.Errorf("%s: X-Request-ID = %q, want a fresh one", s.path, s.requestID)
+		}
+		ids[s.requestID] = true
+		wantCT := ""
+		if s.path == portalEndpoint {
+			wantCT = "application/json"
+		}
+		if s.contentType != wantCT {
+			t.Errorf("%s: Content-Type = %q, want %q", s.path, s.contentType, wantCT)
+		}
+	}
+}
+
+func TestRequestIDKeptAcrossRetries(t *testing.T) {
+	var ids []string
+	var mu sync.Mutex
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ids = append(ids, r.Header.Get("X-Request-ID"))
+		n := len(ids)
+		mu.Unlock()
+		if n == 1 {
+			writeJSON(w, http.StatusBadGateway, `{}`)
+			return
+		}
+		writeJSON(w, http.StatusOK, statusBody)
+	}), WithRetry(1, time.Millisecond, false))
+	if _, err := c.Status(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] == "" || ids[0] != ids[1] {
+		t.Errorf("request IDs = %q, want the same one on both attempts", ids)
+	}
+}
+
+// recordingDoer answers every request itself from a handler, recording it.
+type recordingDoer struct {
+	h    http.Handler
+	mu   sync.Mutex
+	reqs []*http.Request
+}
+
+func (d *recordingDoer) Do(req *http.Request) (*http.Response, error) {
+	d.mu.Lock()
+	d.reqs = append(d.reqs, req)
+	d.mu.Unlock()
+	rec := httptest.NewRecorder()
+	d.h.ServeHTTP(rec, req)
+	res := rec.Result()
+	res.Request = req
+	return res, nil
+}
+
+// failTransport fails the test if anything is sent through it.
+type failTransport struct{ t *testing.T }
+
+func (f failTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.t.Errorf("request to %s bypassed the Doer", req.URL)
+	return nil, errors.New("bypassed the Doer")
+}
+
+func TestDoer(t *testing.T) {
+	// Two failures open the breaker, so a probe goes out before the status
+	// read is retried.
+	var statuses atomic.Int64
+	doer := &recordingDoer{h: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == portalEndpoint:
+			writeJSON(w, http.StatusOK, `{"morties_sent":1,"survived":true,"morties_in_citadel":1,"morties_on_planet_jessica":1,"morties_lost":0,"steps_taken":1}`)
+		case r.URL.Path == statusEndpoint && statuses.Add(1) <= 2:
+			writeJSON(w, http.StatusServiceUnavailable, `{}`)
+		default:
+			writeJSON(w, http.StatusOK, statusBody)
+		}
+	})}
+	c := NewClient("http://api.invalid", "token", &http.Client{Transport: failTransport{t}},
+		WithDoer(doer),
+		WithRetry(3, time.Millisecond, true),
+		WithBreaker(2, time.Millisecond, 1),
+	)
+	ctx := context.Background()
+	if _, err := c.StartEpisode(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SendMorties(ctx, [3]int{1, 1, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Status(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := c.BreakerStats(); stats.Trips != 1 {
+		t.Errorf("breaker tripped %d times, want 1", stats.Trips)
+	}
+
+	// Start, three sends, two failed status reads, the probe and the
+	// status read that got through.
+	wantPaths := []string{startEndpoint, portalEndpoint, portalEndpoint, portalEndpoint,
+		statusEndpoint, statusEndpoint, statusEndpoint, statusEndpoint}
+	if len(doer.reqs) != len(wantPaths) || c.Requests() != int64(len(wantPaths)) {
+		t.Fatalf("Doer saw %d requests and Requests() = %d, want %d", len(doer.reqs), c.Requests(), len(wantPaths))
+	}
+	for i, req := range doer.reqs {
+		if req.URL.Host != "api.invalid" || req.URL.Path != wantPaths[i] {
+			t.Errorf("request %d went to %s, want %s", i, req.URL, wantPaths[i])
+		}
+	}
+}
+
+func TestBaseURLWithPath(t *testing.T) {
+	var paths []string
+	var mu sync.Mutex
+	base := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		writeJSON(w, http.StatusOK, statusBody)
+	}))
+	for _, prefix := range []string{"/staging", "/staging/"} {
+		paths = nil
+		parsed, err := ParseBaseURL(base + prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := NewClient(parsed, "token", nil)
+		for range 2 {
+			if _, err := c.Status(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// A request must not change the URL the next one is built from.
+		want := "/staging" + statusEndpoint
+		if len(paths) != 2 || paths[0] != want || paths[1] != want {
+			t.Errorf("base %s: server saw %q, want %s twice", prefix, paths, want)
+		}
+	}
+}
+
+func TestInvalidBaseURL(t *testing.T) {
+	c := NewClient("http://bad host", "token", nil)
+	if _, err := c.Status(context.Background()); !errors.Is(err, ErrRequest) {
+		t.Errorf("err = %v, want ErrRequest", err)
+	}
+}
+
+func TestParseBaseURL(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "https://challenge.sphinxhq.com", want: "https://challenge.sphinxhq.com"},
+		{in: "https://challenge.sphinxhq.com/", want: "https://challenge.sphinxhq.com"},
+		{in: "http://localhost:8000/prefix/", want: "http://localhost:8000/prefix"},
+		{in: "localhost:8000", wantErr: true},
+		{in: "ftp://example.com", wantErr: true},
+		{in: "http://", wantErr: true},
+		{in: "http://example.com/?a=b", wantErr: true},
+		{in: "http://example.com/#top", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBaseURL(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseBaseURL(%q) = %q, %v, want %q, error %t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// BenchmarkNewRequest compares building a portal request from the endpoint
+// string, as the client used to, with copying the parsed endpoint.
+func BenchmarkNewRequest(b *testing.B) {
+	ctx := context.Background()
+	body := []byte(`{"planet":0,"morty_count":3}` + "\n")
+	c := NewClient(DefaultBaseURL, "token", nil)
+	raw := c.portalURL.String()
+	b.Run("parse", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := http.NewRequestWithContext(ctx, http.MethodPost, raw, bytes.NewReader(body)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("copy", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := newRequest(ctx, http.MethodPost, c.portalURL, body); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// WithStrictDecode makes responses that carry unknown fields or leave out
+// fields the client relies on fail with a *DecodeError, so a change in the
+// API shows up at once instead of as zero counts. Off by default, since the
+// server adding a field is harmless.
+func WithStrictDecode(strict bool) Option {
+	return func(c *Client) {
+		c.strictDecode = strict
+	}
+}
+
+// DecodeError is returned when a response body cannot be decoded, or breaks
+// strict decoding.
+type DecodeError struct {
+	// Endpoint is the path of the request whose response failed to decode.
+	Endpoint string
+	// RequestID is the X-Request-ID the request was sent with.
+	RequestID string
+	Body      string
+	Err       error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decoding %s response to request %s %q: %v", e.Endpoint, e.RequestID, e.Body, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// requiredFielder is implemented by responses with fields that strict
+// decoding insists are present rather than defaulted.
+type requiredFielder interface {
+	requiredFields() []string
+}
+
+func (Status) requiredFields() []string {
+	return []string{"morties_in_citadel", "steps_taken"}
+}
+
+func (Portal) requiredFields() []string {
+	return []string{"morties_sent", "survived", "morties_in_citadel", "steps_taken"}
+}
+
+// decode unmarshals b into a T. In strict mode unknown fields are rejected
+// and every required field must be present and not null.
+func decode[T any](b []byte, strict bool) (T, error) {
+	var v T
+	if !strict {
+		err := json.Unmarshal(b, &v)
+		return v, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		return v, err
+	}
+	// Status collects unknown fields into Extra itself, so the decoder
+	// never sees them as unknown.
+	if s, ok := any(v).(Status); ok && len(s.Extra) > 0 {
+		return v, fmt.Errorf("%w: unknown fields %v", ErrContract, slices.Sorted(maps.Keys(s.Extra)))
+	}
+	r, ok := any(v).(requiredFielder)
+	if !ok {
+		return v, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return v, err
+	}
+	for _, name := range r.requiredFields() {
+		if raw, ok := fields[name]; !ok || string(raw) == "null" {
+			return v, fmt.Errorf("%w: missing field %q", ErrContract, name)
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStrictDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		// wantStrict is the field a strict client should complain about,
+		// or empty if it should accept the body.
+		wantStrict string
+	}{
+		{name: "complete", body: statusBody},
+		{name: "missing required field", body: `{"morties_in_citadel":1000,"morties_lost":0}`, wantStrict: "steps_taken"},
+		{name: "null required field", body: `{"morties_in_citadel":null,"steps_taken":0}`, wantStrict: "morties_in_citadel"},
+		{name: "optional fields left out", body: `{"morties_in_citadel":1000,"steps_taken":0}`},
+		{name: "unknown field", body: `{"morties_in_citadel":1000,"steps_taken":0,"difficulty":"hard"}`, wantStrict: "difficulty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, tt.body)
+			})
+
+			// The default is lenient, whatever the body leaves out or adds.
+			s, err := newTestClient(t, h).Status(context.Background())
+			if err != nil {
+				t.Fatalf("lenient: Status: %v", err)
+			}
+			if s.MortiesInCitadel != 1000 && tt.wantStrict != "morties_in_citadel" {
+				t.Errorf("lenient: MortiesInCitadel = %d, want 1000", s.MortiesInCitadel)
+			}
+
+			_, err = newTestClient(t, h, WithStrictDecode(true)).Status(context.Background())
+			if tt.wantStrict == "" {
+				if err != nil {
+					t.Errorf("strict: Status: %v", err)
+				}
+				return
+			}
+			var decErr *DecodeError
+			if !errors.As(err, &decErr) || !errors.Is(err, ErrContract) {
+				t.Fatalf("strict: err = %v, want a *DecodeError wrapping ErrContract", err)
+			}
+			if decErr.Endpoint != statusEndpoint || decErr.Body != tt.body || decErr.RequestID == "" {
+				t.Errorf("strict: DecodeError = %+v, want the endpoint, body and request ID", decErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantStrict) {
+				t.Errorf("strict: err = %v, want it to name %q", err, tt.wantStrict)
+			}
+		})
+	}
+}
+
+func TestStrictDecodePortal(t *testing.T) {
+	tests := []struct {
+		body    string
+		wantErr bool
+	}{
+		{body: `{"morties_sent":1,"survived":true,"morties_in_citadel":999,"morties_on_planet_jessica":1,"morties_lost":0,"steps_taken":1}`},
+		{body: `{"morties_sent":1,"morties_in_citadel":999,"steps_taken":1}`, wantErr: true},
+		{body: `{"morties_sent":1,"survived":true,"morties_in_citadel":999,"steps_taken":1,"bonus":2}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		for _, strict := range []bool{false, true} {
+			_, err := decode[Portal]([]byte(tt.body), strict)
+			if want := strict && tt.wantErr; (err != nil) != want {
+				t.Errorf("strict %t: decode(%s) err = %v, want error %t", strict, tt.body, err, want)
+			}
+		}
+	}
+}
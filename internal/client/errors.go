@@ -0,0 +1,139 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrRequest is wrapped by errors building a request, before anything was
+// sent.
+var ErrRequest = errors.New("creating request")
+
+// ErrTransport is wrapped by errors sending a request or reading its
+// response, where the API never gave a complete answer.
+var ErrTransport = errors.New("sending request")
+
+// PlanetError is returned by SendMorties when the send to one planet failed.
+type PlanetError struct {
+	Planet int
+	Err    error
+}
+
+func (e *PlanetError) Error() string {
+	return fmt.Sprintf("sending to planet %d: %v", e.Planet, e.Err)
+}
+
+func (e *PlanetError) Unwrap() error { return e.Err }
+
+// bodySnippetLen is how much of an error response body APIError keeps.
+const bodySnippetLen = 512
+
+// APIError is returned when the API answers with a non-2xx status code, or
+// with a 2xx status code and an error object instead of an answer.
+type APIError struct {
+	StatusCode int
+	// Endpoint is the path of the request that failed.
+	Endpoint string
+	// RequestID is the X-Request-ID the request was sent with.
+	RequestID string
+	// Body is the start of the response body.
+	Body string
+	// Message is the error a 2xx body carried, empty for other statuses.
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s returned %d with error %q (request %s)", e.Endpoint, e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("%s returned %d %s (request %s): %s", e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode), e.RequestID, e.Body)
+}
+
+// Unauthorized reports whether the API rejected the credentials.
+func (e *APIError) Unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// NonJSONError is returned when a successful response is not JSON, such as
+// an HTML page from a proxy or an empty body.
+type NonJSONError struct {
+	StatusCode  int
+	Endpoint    string
+	RequestID   string
+	ContentType string
+	// Body is the start of the response body.
+	Body string
+}
+
+func (e *NonJSONError) Error() string {
+	return fmt.Sprintf("%s returned %d with a non-JSON body (request %s, content type %q): %q",
+		e.Endpoint, e.StatusCode, e.RequestID, e.ContentType, e.Body)
+}
+
+// checkJSON returns a *NonJSONError unless the response is labelled as JSON,
+// or unlabelled, and its body looks like a JSON object.
+func checkJSON(res *http.Response, body []byte) error {
+	ct := res.Header.Get("Content-Type")
+	isJSON := ct == ""
+	if mt, _, err := mime.ParseMediaType(ct); err == nil {
+		isJSON = mt == "application/json" || strings.HasSuffix(mt, "+json")
+	}
+	if isJSON && bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		return nil
+	}
+	return &NonJSONError{
+		StatusCode:  res.StatusCode,
+		Endpoint:    res.Request.URL.Path,
+		RequestID:   res.Request.Header.Get("X-Request-ID"),
+		ContentType: ct,
+		Body:        string(body[:min(len(body), bodySnippetLen)]),
+	}
+}
+
+// checkErrorBody returns an *APIError if a 2xx body is an error object, like
+// {"error": "episode not active"}, rather than an answer. Decoding it would
+// give a zero Status or Portal that looks like a real one.
+func checkErrorBody(res *http.Response, body []byte) error {
+	var e struct {
+		Error *string `json:"error"`
+	}
+	if json.Unmarshal(body, &e) != nil || e.Error == nil {
+		return nil
+	}
+	return &APIError{
+		StatusCode: res.StatusCode,
+		Endpoint:   res.Request.URL.Path,
+		RequestID:  res.Request.Header.Get("X-Request-ID"),
+		Body:       string(body[:min(len(body), bodySnippetLen)]),
+		Message:    *e.Error,
+	}
+}
+
+// checkResponse returns nil for a 2xx response and an *APIError otherwise.
+// Rate limits and server errors are wrapped so the retry logic picks them up.
+func checkResponse(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, bodySnippetLen))
+	apiErr := &APIError{
+		StatusCode: res.StatusCode,
+		Endpoint:   res.Request.URL.Path,
+		RequestID:  res.Request.Header.Get("X-Request-ID"),
+		Body:       string(b),
+	}
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests:
+		return rateLimited(res, apiErr)
+	case res.StatusCode >= http.StatusInternalServerError:
+		return retryableError{err: apiErr}
+	default:
+		return apiErr
+	}
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// notActiveBody is a portal answer captured from the live API.
+const notActiveBody = `{"error": "episode not active"}`
+
+func TestErrorBodyUnder200(t *testing.T) {
+	var hits atomic.Int64
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		writeJSON(w, http.StatusOK, notActiveBody)
+	}), WithRetry(3, time.Millisecond, true))
+
+	_, err := c.SendMorties(context.Background(), [3]int{1, 0, 0})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("SendMorties err = %v, want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusOK || apiErr.Message != "episode not active" {
+		t.Errorf("APIError = %+v, want 200 with message %q", apiErr, "episode not active")
+	}
+	var planetErr *PlanetError
+	if !errors.As(err, &planetErr) || planetErr.Planet != 0 {
+		t.Errorf("err = %v, want a *PlanetError for planet 0", err)
+	}
+
+	if _, err := c.Status(context.Background()); !errors.As(err, &apiErr) {
+		t.Fatalf("Status err = %v, want an *APIError", err)
+	}
+	// An error the API answered with is final, even when retries are on.
+	if got := hits.Load(); got != 2 {
+		t.Errorf("server saw %d requests, want 2", got)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		code             int
+		wantUnauthorized bool
+		wantHits         int64
+	}{
+		{code: http.StatusUnauthorized, wantUnauthorized: true, wantHits: 1},
+		{code: http.StatusNotFound, wantHits: 1},
+		// Server errors are retried before being given up on.
+		{code: http.StatusInternalServerError, wantHits: 3},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.code), func(t *testing.T) {
+			var hits atomic.Int64
+			body := strings.Repeat("x", 2*bodySnippetLen)
+			c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				http.Error(w, body, tt.code)
+			}), WithRetry(2, time.Millisecond, false))
+
+			_, err := c.Status(context.Background())
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v, want an *APIError", err)
+			}
+			if apiErr.StatusCode != tt.code || apiErr.Endpoint != statusEndpoint {
+				t.Errorf("APIError = %d for %s, want %d for %s", apiErr.StatusCode, apiErr.Endpoint, tt.code, statusEndpoint)
+			}
+			if apiErr.RequestID == "" {
+				t.Error("APIError has no request ID")
+			}
+			if len(apiErr.Body) != bodySnippetLen {
+				t.Errorf("APIError body is %d bytes, want it cut to %d", len(apiErr.Body), bodySnippetLen)
+			}
+			if apiErr.Unauthorized() != tt.wantUnauthorized {
+				t.Errorf("Unauthorized() = %t, want %t", apiErr.Unauthorized(), tt.wantUnauthorized)
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("server saw %d requests, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestNonJSONBodies(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantNonJSON bool
+		wantHits    int64
+	}{
+		{name: "html", contentType: "text/html; charset=utf-8", body: "<html><body>Bad Gateway</body></html>", wantNonJSON: true, wantHits: 3},
+		{name: "plain text", contentType: "text/plain", body: "unauthorized", wantNonJSON: true, wantHits: 3},
+		{name: "empty", contentType: "application/json", body: "", wantNonJSON: true, wantHits: 3},
+		{name: "html labelled json", contentType: "application/json", body: "<!DOCTYPE html>", wantNonJSON: true, wantHits: 3},
+		// It looks like JSON, so it is decoded, fails, and is not retried.
+		{name: "truncated", contentType: "application/json", body: `{"morties_in_citadel": 9`, wantHits: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int64
+			c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte(tt.body))
+			}), WithRetry(2, time.Millisecond, false))
+
+			status, err := c.Status(context.Background())
+			if err == nil {
+				t.Fatalf("Status = %+v, want an error", status)
+			}
+			var nonJSON *NonJSONError
+			var decodeErr *DecodeError
+			if tt.wantNonJSON {
+				if !errors.As(err, &nonJSON) {
+					t.Fatalf("err = %v, want a *NonJSONError", err)
+				}
+				if nonJSON.StatusCode != http.StatusOK || nonJSON.ContentType != tt.contentType || nonJSON.Body != tt.body {
+					t.Errorf("NonJSONError = %+v", nonJSON)
+				}
+			} else if !errors.As(err, &decodeErr) || decodeErr.Body != tt.body {
+				t.Fatalf("err = %v, want a *DecodeError with the body", err)
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("server saw %d requests, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
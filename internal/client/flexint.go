@@ -0,0 +1,38 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ErrContract is returned when a response decodes but breaks the shape the
+// API is expected to return.
+var ErrContract = errors.New("response violates API contract")
+
+// FlexInt is a Morty count that tolerates the server sending integral floats
+// such as 3.0. Fractional values and non-numbers are rejected with
+// ErrContract; null leaves the value untouched like it would for an int.
+type FlexInt int
+
+func (n *FlexInt) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 0); err == nil {
+		*n = FlexInt(i)
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("%w: count %s is not a number", ErrContract, s)
+	}
+	// MaxInt rounds up to 2^63 as a float64, which is already too big.
+	if f != math.Trunc(f) || f >= math.MaxInt || f < math.MinInt {
+		return fmt.Errorf("%w: count %s is not a whole number", ErrContract, s)
+	}
+	*n = FlexInt(f)
+	return nil
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestFlexInt(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    FlexInt
+		wantErr bool
+	}{
+		{name: "int", in: `3`, want: 3},
+		{name: "zero", in: `0`, want: 0},
+		{name: "negative", in: `-2`, want: -2},
+		{name: "integral float", in: `3.0`, want: 3},
+		{name: "exponent", in: `1e3`, want: 1000},
+		{name: "fractional float", in: `3.5`, wantErr: true},
+		{name: "tiny fraction", in: `2.000001`, wantErr: true},
+		{name: "string number", in: `"3"`, wantErr: true},
+		{name: "string", in: `"three"`, wantErr: true},
+		{name: "bool", in: `true`, wantErr: true},
+		{name: "too big", in: `1e19`, wantErr: true},
+		// null leaves the count as it was, as it would for an int.
+		{name: "null", in: `null`, want: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := FlexInt(7)
+			err := json.Unmarshal([]byte(tt.in), &n)
+			if tt.wantErr {
+				if !errors.Is(err, ErrContract) {
+					t.Errorf("Unmarshal(%s) err = %v, want ErrContract", tt.in, err)
+				}
+				return
+			}
+			if err != nil || n != tt.want {
+				t.Errorf("Unmarshal(%s) = %d, %v, want %d", tt.in, n, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlexIntInStatus(t *testing.T) {
+	// The body that broke every status call when the server started
+	// sending floats.
+	body := `{"morties_in_citadel":997.0,"morties_on_planet_jessica":0,"morties_lost":3.0,"steps_taken":1,"status_message":""}`
+	for _, strict := range []bool{false, true} {
+		s, err := decode[Status]([]byte(body), strict)
+		if err != nil || s.MortiesInCitadel != 997 || s.MortiesLost != 3 {
+			t.Errorf("strict %t: decode = %+v, %v", strict, s, err)
+		}
+	}
+	if _, err := decode[Status]([]byte(`{"morties_in_citadel":1.5,"steps_taken":1}`), false); !errors.Is(err, ErrContract) {
+		t.Errorf("fractional count: err = %v, want ErrContract", err)
+	}
+}
+
+func FuzzFlexInt(f *testing.F) {
+	for _, seed := range []string{`3`, `3.0`, `3.5`, `"3"`, `null`, `-0`, `1e3`, `9223372036854775807`, `9.223372036854775807e18`} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		var n FlexInt
+		if err := json.Unmarshal([]byte(in), &n); err != nil || in == "null" {
+			return
+		}
+		// Anything accepted is a whole number JSON agrees on.
+		var v float64
+		if err := json.Unmarshal([]byte(in), &v); err != nil {
+			t.Fatalf("accepted %q, which is not a number: %v", in, err)
+		}
+		if v != math.Trunc(v) || float64(n) != v {
+			t.Fatalf("accepted %q as %d, want %v", in, n, v)
+		}
+	})
+}
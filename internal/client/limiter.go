@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithRateLimit spaces requests so that no more than rps are made per second
+// on average. A non-positive rps means no limit.
+func WithRateLimit(rps float64) Option {
+	return func(c *Client) {
+		if rps > 0 {
+			c.limiter = &limiter{
+				interval: time.Duration(float64(time.Second) / rps),
+				now:      time.Now,
+				sleep:    sleep,
+			}
+		}
+	}
+}
+
+// limiter is a token bucket holding a single token: each request takes the
+// next free slot, one interval after the previous one. A nil *limiter never
+// waits.
+type limiter struct {
+	interval time.Duration
+	// now and sleep are the clock, replaced in tests.
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until the caller may make a request or ctx is done.
+func (l *limiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := l.now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		return l.sleep(ctx, d)
+	}
+	return nil
+}
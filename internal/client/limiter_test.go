@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock whose sleeps return at once and move it forward.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.t = c.t.Add(d)
+	return nil
+}
+
+func TestLimiterRate(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := &Client{}
+	WithRateLimit(2)(c)
+	c.limiter.now, c.limiter.sleep = clock.now, clock.sleep
+
+	start := clock.t
+	for range 10 {
+		if err := c.limiter.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first request goes at once and each one after waits half a
+	// second.
+	if got, want := clock.t.Sub(start), 4500*time.Millisecond; got != want {
+		t.Errorf("10 requests at 2 rps took %s, want %s", got, want)
+	}
+}
+
+func TestLimiterCancelled(t *testing.T) {
+	c := &Client{}
+	WithRateLimit(0.001)(c)
+	if err := c.limiter.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	if err := c.limiter.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("wait took %s to return after cancel", elapsed)
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	c := &Client{}
+	WithRateLimit(0)(c)
+	if c.limiter != nil {
+		t.Fatal("rps 0 set up a limiter")
+	}
+	if err := c.limiter.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxBackoff caps the wait between two attempts.
+const maxBackoff = 30 * time.Second
+
+// maxRetryAfter caps how long a Retry-After header can make us wait.
+const maxRetryAfter = 5 * time.Minute
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithRetry retries failed requests up to maxRetries times, waiting an
+// exponentially growing, jittered delay starting at base between attempts.
+// Portal requests move Morties, so they are only retried if retryPortal is
+// set; start and status requests are always safe to repeat.
+func WithRetry(maxRetries int, base time.Duration, retryPortal bool) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryBase = base
+		c.retryPortal = retryPortal
+	}
+}
+
+// retryableError marks a failure worth trying again: the request never got
+// an answer or the server had a problem of its own.
+type retryableError struct {
+	err error
+	// after is how long the server asked us to wait, if it said.
+	after time.Duration
+	// rejected means the server turned the request away without acting on
+	// it, so even a portal request is safe to repeat.
+	rejected bool
+}
+
+func (e retryableError) Error() string { return e.err.Error() }
+func (e retryableError) Unwrap() error { return e.err }
+
+// rateLimited wraps the error for a 429 response so it is retried after the
+// delay in its Retry-After header.
+func rateLimited(res *http.Response, err error) retryableError {
+	after, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+	if ok && after > maxRetryAfter {
+		slog.Warn("Retry-After too long, capping it", "retry_after", after, "cap", maxRetryAfter)
+		after = maxRetryAfter
+	}
+	return retryableError{
+		err:      err,
+		after:    after,
+		rejected: true,
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date. Dates in the past mean no wait.
+func parseRetryAfter(h string, now time.Time) (time.Duration, bool) {
+	if h == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(h); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(h)
+	if err != nil {
+		return 0, false
+	}
+	return max(0, t.Sub(now)), true
+}
+
+// retry calls attempt for the request with the given ID until it succeeds,
+// fails in a way that is not worth retrying, or runs out of retries. When
+// retryable is false only requests the server rejected outright are
+// retried, and nothing is once ctx is done.
+func (c *Client) retry(ctx context.Context, id string, retryable bool, attempt func() error) error {
+	for n := 0; ; n++ {
+		if err := c.breaker.wait(ctx, c.probe); err != nil {
+			return err
+		}
+		err := attempt()
+		c.breaker.record(err)
+		if err == nil || n >= c.maxRetries || ctx.Err() != nil {
+			return err
+		}
+		var re retryableError
+		if !errors.As(err, &re) || (!retryable && !re.rejected) {
+			return err
+		}
+		if re.after > 0 {
+			slog.Info("rate limited, waiting before retrying", "wait", re.after, "attempt", n+1, "request_id", id)
+			if err := sleep(ctx, re.after); err != nil {
+				return err
+			}
+			continue
+		}
+		wait := c.backoff(n)
+		slog.Warn("request failed, retrying",
+			"attempt", n+1,
+			"max_retries", c.maxRetries,
+			"wait", wait,
+			"request_id", id,
+			"error", err,
+		)
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns the wait before retry n+1: base doubled n times, capped at
+// maxBackoff, with the upper half randomized so clients do not retry in step.
+func (c *Client) backoff(n int) time.Duration {
+	d := min(c.retryBase<<n, maxBackoff)
+	if d <= 0 {
+		// The shift overflowed or no base was configured.
+		d = maxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
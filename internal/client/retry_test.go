@@ -0,0 +1,235 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flaky answers the first n requests with code and a status after that.
+func flaky(n int64, code int, hits *atomic.Int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= n {
+			writeJSON(w, code, `{"detail":"try again"}`)
+			return
+		}
+		writeJSON(w, http.StatusOK, statusBody)
+	}
+}
+
+func TestRetryFlakyServer(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int64
+		maxRetries  int
+		retryPortal bool
+		portal      bool
+		wantErr     bool
+		wantHits    int64
+	}{
+		{name: "recovers", failures: 2, maxRetries: 3, wantHits: 3},
+		{name: "recovers on last retry", failures: 3, maxRetries: 3, wantHits: 4},
+		{name: "runs out of retries", failures: 10, maxRetries: 3, wantErr: true, wantHits: 4},
+		{name: "retries disabled", failures: 1, maxRetries: 0, wantErr: true, wantHits: 1},
+		{name: "portal not retried", failures: 1, maxRetries: 3, portal: true, wantErr: true, wantHits: 1},
+		{name: "portal retried when enabled", failures: 1, maxRetries: 3, portal: true, retryPortal: true, wantHits: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int64
+			c := newTestClient(t, flaky(tt.failures, http.StatusInternalServerError, &hits),
+				WithRetry(tt.maxRetries, time.Millisecond, tt.retryPortal))
+			var err error
+			if tt.portal {
+				_, err = c.sendPlanet(context.Background(), SendMorty{Planet: 0, MortyCount: 1})
+			} else {
+				_, err = c.Status(context.Background())
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %t", err, tt.wantErr)
+			}
+			var apiErr *APIError
+			if err != nil && (!errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError) {
+				t.Errorf("err = %v, want a 500 *APIError", err)
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("server saw %d requests, want %d", got, tt.wantHits)
+			}
+			if got := c.Requests(); got != tt.wantHits {
+				t.Errorf("Requests() = %d, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestRetryConnectionReset(t *testing.T) {
+	var hits atomic.Int64
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		writeJSON(w, http.StatusOK, statusBody)
+	}), WithRetry(3, time.Millisecond, false))
+	if _, err := c.Status(context.Background()); err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("server saw %d requests, want 2", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := &Client{retryBase: 100 * time.Millisecond}
+	for n := range 12 {
+		d := min(c.retryBase<<n, maxBackoff)
+		for range 20 {
+			if got := c.backoff(n); got < d/2 || got > d {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", n, got, d/2, d)
+			}
+		}
+	}
+	if got := c.backoff(100); got > maxBackoff {
+		t.Errorf("backoff(100) = %s, want at most %s", got, maxBackoff)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		{header: "", wantOK: false},
+		{header: "7", want: 7 * time.Second, wantOK: true},
+		{header: "0", want: 0, wantOK: true},
+		{header: "-3", wantOK: false},
+		{header: "soon", wantOK: false},
+		{header: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second, wantOK: true},
+		{header: now.Add(-time.Hour).Format(http.TimeFormat), want: 0, wantOK: true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.header, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %s, %t, want %s, %t", tt.header, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// limited answers the first request with a 429 carrying retryAfter, if set,
+// and a status after that.
+func limited(retryAfter string, hits *atomic.Int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			writeJSON(w, http.StatusTooManyRequests, `{"detail":"slow down"}`)
+			return
+		}
+		writeJSON(w, http.StatusOK, statusBody)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		minWait    time.Duration
+	}{
+		{name: "seconds", retryAfter: "1", minWait: time.Second},
+		// Without a Retry-After the usual backoff applies.
+		{name: "missing", retryAfter: "", minWait: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int64
+			c := newTestClient(t, limited(tt.retryAfter, &hits), WithRetry(3, time.Millisecond, false))
+			start := time.Now()
+			// A 429 did nothing, so even a portal send is repeated.
+			if _, err := c.sendPlanet(context.Background(), SendMorty{Planet: 0, MortyCount: 1}); err != nil {
+				t.Fatalf("sendPlanet: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minWait {
+				t.Errorf("retried after %s, want at least %s", elapsed, tt.minWait)
+			}
+			if got := hits.Load(); got != 2 {
+				t.Errorf("server saw %d requests, want 2", got)
+			}
+		})
+	}
+}
+
+func TestRetryAfterCapped(t *testing.T) {
+	res := &http.Response{Header: http.Header{"Retry-After": {"999999999"}}}
+	if got := rateLimited(res, errors.New("429")).after; got != maxRetryAfter {
+		t.Errorf("wait = %s, want it capped at %s", got, maxRetryAfter)
+	}
+}
+
+func TestRetryAfterCancelled(t *testing.T) {
+	var hits atomic.Int64
+	c := newTestClient(t, limited("3600", &hits), WithRetry(3, time.Millisecond, false))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := c.Status(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Status took %s to return after cancel", elapsed)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("server saw %d requests, want 1", got)
+	}
+}
+
+func TestClosedPort(t *testing.T) {
+	// Nothing listens on the port once the listener is closed.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "http://" + ln.Addr().String()
+	ln.Close()
+
+	const maxRetries = 2
+	c := NewClient(base, "token", &http.Client{Timeout: 5 * time.Second}, WithRetry(maxRetries, time.Millisecond, false))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	calls := []struct {
+		name string
+		call func() error
+		// wantAttempts is 1 for the portal, which is only retried when the
+		// server turned the request away.
+		wantAttempts int64
+	}{
+		{name: "start", call: func() error { _, err := c.StartEpisode(ctx); return err }, wantAttempts: maxRetries + 1},
+		{name: "status", call: func() error { _, err := c.Status(ctx); return err }, wantAttempts: maxRetries + 1},
+		{name: "portal", call: func() error { _, err := c.SendMorties(ctx, [3]int{1, 0, 0}); return err }, wantAttempts: 1},
+	}
+	for _, tt := range calls {
+		before := c.Requests()
+		err := tt.call()
+		if !errors.Is(err, ErrTransport) || !errors.Is(err, syscall.ECONNREFUSED) {
+			t.Errorf("%s: err = %v, want ErrTransport wrapping ECONNREFUSED", tt.name, err)
+		}
+		if IsTimeout(err) || ctx.Err() != nil {
+			t.Errorf("%s: err = %v taken for a timeout", tt.name, err)
+		}
+		if got := c.Requests() - before; got != tt.wantAttempts {
+			t.Errorf("%s: %d attempts, want %d", tt.name, got, tt.wantAttempts)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// TraceTransport wraps next so every request and response is logged at
+// debug level, with bodies cut to bodyLimit bytes. The Authorization header
+// is redacted. A nil next means http.DefaultTransport; a negative bodyLimit
+// is taken as zero.
+func TraceTransport(next http.RoundTripper, bodyLimit int) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &traceTransport{next: next, bodyLimit: max(0, bodyLimit)}
+}
+
+type traceTransport struct {
+	next      http.RoundTripper
+	bodyLimit int
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return t.next.RoundTrip(req)
+	}
+
+	// The request body belongs to the transport, so log a fresh copy.
+	var reqBody []byte
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			reqBody, _ = io.ReadAll(io.LimitReader(body, int64(t.bodyLimit)))
+			body.Close()
+		}
+	}
+	header := req.Header.Clone()
+	if header.Get("Authorization") != "" {
+		header.Set("Authorization", "REDACTED")
+	}
+
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		slog.DebugContext(ctx, "http request failed",
+			"method", req.Method,
+			"url", req.URL.String(),
+			"header", header,
+			"request_body", string(reqBody),
+			"elapsed", time.Since(start),
+			"error", err,
+		)
+		return nil, err
+	}
+
+	// Read the whole body so the caller still gets all of it, but only log
+	// the start.
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+	slog.DebugContext(ctx, "http request",
+		"method", req.Method,
+		"url", req.URL.String(),
+		"header", header,
+		"request_body", string(reqBody),
+		"status", res.StatusCode,
+		"response_body", string(resBody[:min(len(resBody), t.bodyLimit)]),
+		"elapsed", time.Since(start),
+	)
+	return res, nil
+}
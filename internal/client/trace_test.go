@@ -0,0 +1,96 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTraceTransport(t *testing.T) {
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	for _, limit := range []int{-1, 0, 5, 1 << 20} {
+		logs.Reset()
+		c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, statusBody)
+		}), WithTransport(TraceTransport(http.DefaultTransport, limit)))
+		if _, err := c.Status(context.Background()); err != nil {
+			t.Fatalf("limit %d: Status: %v", limit, err)
+		}
+		out := logs.String()
+		if strings.Contains(out, "token") || !strings.Contains(out, "REDACTED") {
+			t.Errorf("limit %d: auth header not redacted in %s", limit, out)
+		}
+		if want := statusBody[:min(len(statusBody), max(0, limit))]; !strings.Contains(out, "response_body="+strings.TrimSpace(quote(want))) {
+			t.Errorf("limit %d: response body %q not logged in %s", limit, want, out)
+		}
+	}
+}
+
+// quote renders s as slog's text handler does.
+func quote(s string) string {
+	var b bytes.Buffer
+	slog.New(slog.NewTextHandler(&b, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key != "v" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})).Info("", "v", s)
+	return strings.TrimPrefix(strings.TrimSpace(b.String()), "v=")
+}
+
+// countingTransport counts the requests to each path before passing them on.
+type countingTransport struct {
+	next  http.RoundTripper
+	mu    sync.Mutex
+	paths map[string]int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.paths[req.URL.Path]++
+	c.mu.Unlock()
+	return c.next.RoundTrip(req)
+}
+
+func TestWithTransport(t *testing.T) {
+	rt := &countingTransport{next: http.DefaultTransport, paths: map[string]int{}}
+	hc := &http.Client{Timeout: 5 * time.Second}
+	c := NewClient(newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == portalEndpoint {
+			portalServer(t, [3]bool{true, false, true}, nil)(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, statusBody)
+	})), "token", hc, WithTransport(rt))
+
+	ctx := context.Background()
+	if _, err := c.StartEpisode(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SendMorties(ctx, [3]int{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Status(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{startEndpoint: 1, portalEndpoint: 3, statusEndpoint: 1}
+	if !maps.Equal(rt.paths, want) {
+		t.Errorf("transport saw %v, want %v", rt.paths, want)
+	}
+	// The http.Client passed in is left alone.
+	if hc.Transport != nil {
+		t.Error("WithTransport modified the http.Client passed to NewClient")
+	}
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type Status struct {
+	MortiesInCitadel       FlexInt `json:"morties_in_citadel"`
+	MortiesOnPlanetJessica FlexInt `json:"morties_on_planet_jessica"`
+	MortiesLost            FlexInt `json:"morties_lost"`
+	StepsTaken             FlexInt `json:"steps_taken"`
+	StatusMessage          string  `json:"status_message"`
+
+	// Extra holds any fields the response carried beyond the ones above,
+	// such as a variant hint on the start response. Nil if there were none.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// statusFields are the JSON names of the fields Status decodes itself.
+var statusFields = []string{
+	"morties_in_citadel",
+	"morties_on_planet_jessica",
+	"morties_lost",
+	"steps_taken",
+	"status_message",
+}
+
+// UnmarshalJSON decodes the known fields and collects the rest into Extra.
+func (s *Status) UnmarshalJSON(b []byte) error {
+	type plain Status
+	if err := json.Unmarshal(b, (*plain)(s)); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	for _, name := range statusFields {
+		delete(fields, name)
+	}
+	s.Extra = nil
+	if len(fields) > 0 {
+		s.Extra = fields
+	}
+	return nil
+}
+
+// Phases reported by Status.Phase.
+const (
+	PhaseUnknown    = "unknown"
+	PhaseInProgress = "in_progress"
+	PhaseCompleted  = "completed"
+)
+
+// Phase derives the episode phase from the counts. An all-zero Status is
+// what a failed or unauthorized request decodes to, so it is unknown.
+func (s Status) Phase() string {
+	switch {
+	case s.MortiesInCitadel > 0:
+		return PhaseInProgress
+	case s.MortiesOnPlanetJessica+s.MortiesLost > 0:
+		return PhaseCompleted
+	default:
+		return PhaseUnknown
+	}
+}
+
+// Saved is the number of Morties that made it to Planet Jessica. It is the
+// one count every report of how many were saved should use; the portal
+// response carries the same counter, but only the status read ends a step.
+func (s Status) Saved() int {
+	return int(s.MortiesOnPlanetJessica)
+}
+
+// SavedRate is the fraction of Morties that have left the Citadel and made it
+// to Planet Jessica.
+func (s Status) SavedRate() float64 {
+	done := s.Saved() + int(s.MortiesLost)
+	if done == 0 {
+		return 0
+	}
+	return float64(s.Saved()) / float64(done)
+}
+
+type Portal struct {
+	MortiesSent            FlexInt `json:"morties_sent"`
+	Survived               bool    `json:"survived"`
+	MortiesInCitadel       FlexInt `json:"morties_in_citadel"`
+	MortiesOnPlanetJessica FlexInt `json:"morties_on_planet_jessica"`
+	MortiesLost            FlexInt `json:"morties_lost"`
+	StepsTaken             FlexInt `json:"steps_taken"`
+}
+
+// ISSUE: Dead code - interface defined but never implemented or used
+// Either implement it or remove it
+type MortySender interface {
+	Send(client *http.Client)
+}
+
+type PlanetNumber int
+
+const (
+	OnACob PlanetNumber = iota
+	CronenBergWorld
+	PurgePlanet
+)
+
+// ISSUE: Dead code - type defined but never used
+// All fields remain uninitialized throughout the program
+type Planet struct {
+	PlanetNumber       PlanetNumber
+	CurrentMortyAmount int
+	Survives           int
+	TotalSent          int
+	SurvivalRate       float32
+}
+
+type SendMorty struct {
+	Planet     int `json:"planet"`
+	MortyCount int `json:"morty_count"`
+}
+
+// PlanetResult is one portal request made by SendMorties and its answer.
+type PlanetResult struct {
+	Request SendMorty
+	Portal  Portal
+}
+
+// SendResult is what SendMorties did for a combo, one entry per planet it
+// sent to, in order.
+type SendResult struct {
+	Combo   [3]int
+	Planets []PlanetResult
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+	"testing"
+)
+
+func TestStatusExtra(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		want      Status
+		wantExtra map[string]string
+	}{
+		{
+			name: "no extras",
+			body: `{"morties_in_citadel":1000,"morties_on_planet_jessica":0,"morties_lost":0,"steps_taken":0,"status_message":"go"}`,
+			want: Status{MortiesInCitadel: 1000, StatusMessage: "go"},
+		},
+		{
+			name: "variant hint",
+			body: `{"morties_in_citadel":1000,"steps_taken":0,"variant":"hard","limits":{"max": 3},"seed":42}`,
+			want: Status{MortiesInCitadel: 1000},
+			wantExtra: map[string]string{
+				"variant": `"hard"`,
+				"limits":  `{"max": 3}`,
+				"seed":    `42`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Status
+			if err := json.Unmarshal([]byte(tt.body), &s); err != nil {
+				t.Fatal(err)
+			}
+			extra := s.Extra
+			s.Extra = nil
+			if !reflect.DeepEqual(s, tt.want) {
+				t.Errorf("known fields = %+v, want %+v", s, tt.want)
+			}
+			if tt.wantExtra == nil && extra != nil {
+				t.Errorf("Extra = %v, want nil", extra)
+			}
+			got := make(map[string]string, len(extra))
+			for k, v := range extra {
+				got[k] = string(v)
+			}
+			if tt.wantExtra != nil && !maps.Equal(got, tt.wantExtra) {
+				t.Errorf("Extra = %v, want %v", got, tt.wantExtra)
+			}
+		})
+	}
+}
+
+func TestStatusExtraReset(t *testing.T) {
+	// Decoding into a Status that had extras leaves none behind.
+	s := Status{Extra: map[string]json.RawMessage{"variant": json.RawMessage(`"hard"`)}}
+	if err := json.Unmarshal([]byte(`{"morties_in_citadel":5,"steps_taken":1}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Extra != nil || s.MortiesInCitadel != 5 {
+		t.Errorf("Status = %+v, want 5 in the Citadel and no extras", s)
+	}
+}
+
+func TestStatusRoundTrip(t *testing.T) {
+	in := Status{MortiesInCitadel: 412, MortiesOnPlanetJessica: 390, MortiesLost: 198, StepsTaken: 66, StatusMessage: "in progress"}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Status
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Extra != nil {
+		t.Errorf("round trip grew extras %v from %s", out.Extra, b)
+	}
+	out.Extra = nil
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+	// Marshalling again gives the same bytes.
+	again, err := json.Marshal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(b) {
+		t.Errorf("second marshal = %s, want %s", again, b)
+	}
+}
@@ -0,0 +1,557 @@
+// Package episode runs the epsilon-greedy loop against the challenge API
+// until every Morty has left the Citadel.
+package episode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"savemorty/internal/bandit"
+	"savemorty/internal/client"
+	"savemorty/internal/heartbeat"
+)
+
+// Epsilon is the chance that a step explores a random combo instead of
+// exploiting the best one found so far.
+const Epsilon = 0.4
+
+// ErrNoMorties is returned by Run when the start endpoint reports an empty
+// Citadel, which means nothing was played.
+var ErrNoMorties = errors.New("episode started with no Morties in the Citadel")
+
+// ErrStart is wrapped by the error Run returns when the episode could not be
+// started, so nothing was played.
+var ErrStart = errors.New("starting episode")
+
+// ErrEmptyStatus is returned by Run when every status read after a step came
+// back with no counts at all, which a real episode never reports once Morties
+// have been sent.
+var ErrEmptyStatus = errors.New("status has no counts")
+
+// statusRereads is how many more times an empty status is read before Run
+// gives up, waiting statusRereadWait longer before each one.
+const statusRereads = 3
+
+var statusRereadWait = time.Second
+
+// ErrEpisodeInProgress is returned by Run when an episode was already being
+// played and Config.OnExisting is OnExistingAbort.
+var ErrEpisodeInProgress = errors.New("an episode is already in progress")
+
+// What Run does when the API reports an episode already in progress.
+const (
+	// OnExistingAbort stops with ErrEpisodeInProgress without touching it.
+	OnExistingAbort = "abort"
+	// OnExistingContinue plays the episode on from where it is.
+	OnExistingContinue = "continue"
+	// OnExistingRestart starts a new episode over it.
+	OnExistingRestart = "restart"
+)
+
+// ErrRequestCap is returned by Run when it stopped early because another step
+// would have exceeded Config.MaxRequests, or wrapped when the client refused
+// a request at its own cap partway through a step.
+var ErrRequestCap = client.ErrRequestCap
+
+// ErrNoCombo is returned by Run when the exclusions leave no combo that
+// sends as few Morties as are left in the Citadel.
+var ErrNoCombo = errors.New("no allowed combo fits the Morties left")
+
+// ErrStepTimeout is returned by Run when a step ran past Config.StepTimeout
+// and the policy is StepTimeoutAbort.
+var ErrStepTimeout = errors.New("step timed out")
+
+// What Run does when a step runs past Config.StepTimeout.
+const (
+	// StepTimeoutAbort stops the episode with ErrStepTimeout.
+	StepTimeoutAbort = "abort"
+	// StepTimeoutSkip drops the step and carries on with the next one,
+	// unless maxSkippedSteps in a row have timed out.
+	StepTimeoutSkip = "skip"
+)
+
+// maxSkippedSteps is how many steps in a row StepTimeoutSkip drops before Run
+// stops with ErrStepTimeout, since the API is then unlikely to recover.
+const maxSkippedSteps = 3
+
+// requestsPerStep is the three portal sends plus the status read.
+const requestsPerStep = 4
+
+// Config holds the tunables for a single episode.
+type Config struct {
+	// Space is the set of combos the episode may send.
+	Space *bandit.Space
+	// MinArms is the number of distinct combos that must be tried before
+	// the loop is allowed to exploit. Zero disables the rule.
+	MinArms int
+	// FreezeAfterStep stops updating combo statistics after this many steps
+	// while still exploiting the best combo. Zero never freezes.
+	FreezeAfterStep int
+	// Warmdown stops all exploration once the Morties left in the Citadel
+	// drop below it. It takes precedence over MinArms.
+	Warmdown Threshold
+	// Heartbeat, if set, is rewritten after every step.
+	Heartbeat *heartbeat.File
+	// MaxRequests stops the episode before a step that could take the
+	// client's request count past it. Zero means no cap. It should match
+	// the client's WithMaxRequests, which refuses retries, probes and status
+	// re-reads past the cap within a step.
+	MaxRequests int64
+	// StrictFeasibility makes Run fail instead of warn when the stop
+	// conditions cannot let the episode finish.
+	StrictFeasibility bool
+	// ExploreBudget caps the Morties sent on exploration steps over the
+	// whole episode; once spent, the loop only exploits. Zero means no cap.
+	ExploreBudget int
+	// OnExisting is OnExistingAbort, OnExistingContinue or
+	// OnExistingRestart. Empty means OnExistingRestart.
+	OnExisting string
+	// PruneMargin, if positive, rules out tried combos whose survival rate
+	// interval lies more than this far below the best combo's.
+	PruneMargin float64
+	// StepTimeout bounds each step, its sends and the status read together.
+	// A step that runs out of time is never recorded. Zero means no bound.
+	StepTimeout time.Duration
+	// StepTimeoutPolicy is StepTimeoutAbort or StepTimeoutSkip.
+	StepTimeoutPolicy string
+	// Rand makes every random choice: the epsilon coin flip and the combos
+	// explored. Nil means a freshly seeded source. A Rand is not safe for
+	// concurrent use, so episodes run at once need one each.
+	Rand *rand.Rand
+
+	// afterStep, if set, is called with the combo statistics after every
+	// step that completed. Tests use it to watch the table.
+	afterStep func(step int, actions map[[3]int]*bandit.Action)
+}
+
+// Run starts a new episode and plays it to completion, or until ctx is
+// cancelled or a request fails. It returns the last status it read, which
+// holds the episode's final counts even when it stopped early.
+func Run(ctx context.Context, c *client.Client, cfg Config) (client.Status, error) {
+	beat(cfg.Heartbeat, 0, 0, heartbeat.PhaseStarting)
+	// Starting over an episode that is still being played throws it away,
+	// so look before starting. This also checks the credential.
+	existing, err := c.Status(ctx)
+	if err != nil {
+		beat(cfg.Heartbeat, 0, 0, heartbeat.PhaseFinished)
+		return client.Status{}, fmt.Errorf("%w: checking for an episode in progress: %w", ErrStart, err)
+	}
+	inProgress := existing.MortiesInCitadel > 0 && existing.StepsTaken > 0
+	if inProgress {
+		slog.Warn("an episode is already in progress",
+			"on_existing", cfg.OnExisting,
+			"morties_in_citadel", existing.MortiesInCitadel,
+			"saved", existing.Saved(),
+			"lost", existing.MortiesLost,
+			"steps_taken", existing.StepsTaken,
+		)
+	}
+	var start client.Status
+	switch {
+	case inProgress && cfg.OnExisting == OnExistingAbort:
+		beat(cfg.Heartbeat, 0, int(existing.MortiesInCitadel), heartbeat.PhaseFinished)
+		return existing, fmt.Errorf("%w: %d Morties in the Citadel after %d steps",
+			ErrEpisodeInProgress, existing.MortiesInCitadel, existing.StepsTaken)
+	case inProgress && cfg.OnExisting == OnExistingContinue:
+		start = existing
+	default:
+		start, err = startEpisode(ctx, c)
+		if err != nil {
+			beat(cfg.Heartbeat, 0, 0, heartbeat.PhaseFinished)
+			return client.Status{}, err
+		}
+	}
+
+	// Unknown fields may name an episode variant; keep them in the log so
+	// runs can be told apart later.
+	extra := make([]any, 0, len(start.Extra))
+	for name, raw := range start.Extra {
+		extra = append(extra, slog.String(name, string(raw)))
+	}
+	slog.Info("episode started",
+		"continued", inProgress && cfg.OnExisting == OnExistingContinue,
+		"morties_in_citadel", start.MortiesInCitadel,
+		"saved", start.Saved(),
+		"lost", start.MortiesLost,
+		"steps_taken", start.StepsTaken,
+		"status_message", start.StatusMessage,
+		slog.Group("extra", extra...),
+	)
+
+	mortiesCount := int(start.MortiesInCitadel)
+
+	feasibility := CheckFeasibility(mortiesCount, cfg.Space.MaxBatch(), cfg.MaxRequests, c.Requests())
+	if !feasibility.Feasible() {
+		slog.Warn("episode cannot finish within the request cap",
+			"morties", feasibility.Morties,
+			"max_batch", feasibility.MaxBatch,
+			"steps_needed", feasibility.StepsNeeded,
+			"steps_allowed", feasibility.StepsAllowed,
+			"requests_per_step", requestsPerStep,
+			"max_requests", cfg.MaxRequests,
+		)
+		if cfg.StrictFeasibility {
+			beat(cfg.Heartbeat, 0, mortiesCount, heartbeat.PhaseFinished)
+			return start, ErrInfeasible
+		}
+	}
+	// A continued episode has already sent some of its Morties, so the
+	// total is what a percentage Warmdown is taken of.
+	initialCount := int(start.MortiesInCitadel) + start.Saved() + int(start.MortiesLost)
+	warmdownStep := 0
+	// Morties risked on exploration steps so far, and the step at which
+	// ExploreBudget ran out.
+	exploreSpent, budgetStep := 0, 0
+	// Coin flips actually consulted, and how many of them explored, so the
+	// realized exploration rate can be checked against Epsilon at the end.
+	var eligible, explored int
+	// skipped counts the steps in a row dropped by StepTimeoutSkip.
+	skipped := 0
+	// lastStep is the last step that got as far as a status read, for the
+	// final heartbeat.
+	lastStep := 0
+
+	var actions = map[[3]int]*bandit.Action{}
+	if seed := [3]int{2, 2, 2}; cfg.Space.Allows(seed) {
+		// A guess to exploit before anything is known, not an
+		// observation, so it counts toward neither MinArms nor pruning.
+		actions[seed] = bandit.NewPrior(0.1)
+	}
+	excludedPlanets, excludedCombos := cfg.Space.Excluded()
+	slog.Info("combo space",
+		"combos", len(cfg.Space.Combos()),
+		"excluded_planets", excludedPlanets,
+		"excluded_combos", excludedCombos,
+	)
+	// Pruning is this episode's own; cfg.Space may be shared.
+	pruner := bandit.NewPruner(cfg.PruneMargin)
+	rng := cfg.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	// The gate can never be met if the space is smaller than MinArms.
+	minArms := min(cfg.MinArms, len(cfg.Space.Combos()))
+
+	// last is the most recent status read; its counts are what the episode
+	// reports, never a tally kept here.
+	last := start
+	// stopErr records why the loop ended early, if it did.
+	var stopErr error
+	for step := 1; mortiesCount > 0; step++ {
+		if cfg.MaxRequests > 0 && c.Requests()+requestsPerStep > cfg.MaxRequests {
+			slog.Warn("request cap reached, stopping",
+				"step", step,
+				"requests", c.Requests(),
+				"max_requests", cfg.MaxRequests,
+				"remaining", mortiesCount,
+			)
+			stopErr = ErrRequestCap
+			break
+		}
+
+		// Once frozen, keep exploiting what has been learned but stop
+		// updating it so a late glitch cannot wreck the table.
+		frozen := cfg.FreezeAfterStep > 0 && step > cfg.FreezeAfterStep
+		// With few Morties left, exploration can no longer pay for itself.
+		warmdown := cfg.Warmdown.Below(mortiesCount, initialCount)
+		if warmdown && warmdownStep == 0 {
+			warmdownStep = step
+			slog.Info("warmdown started, exploration disabled",
+				"step", step,
+				"remaining", mortiesCount,
+				"threshold", cfg.Warmdown.String(),
+			)
+		}
+
+		// The budget is spent for good once not even the smallest combo
+		// fits in what is left of it.
+		budgetSpent := budgetStep > 0 ||
+			cfg.ExploreBudget > 0 && exploreSpent+cfg.Space.MinBatch() > cfg.ExploreBudget
+		if budgetSpent && budgetStep == 0 {
+			budgetStep = step
+			slog.Info("exploration budget spent, exploration disabled",
+				"step", step,
+				"explore_spent", exploreSpent,
+				"explore_budget", cfg.ExploreBudget,
+			)
+		}
+
+		randomChance := rng.Float32()
+		slog.Debug("chance", "chance<epsilon", randomChance < Epsilon)
+		// Until enough distinct combos have been observed the "best" one is
+		// just whichever was tried first, so keep exploring untried combos.
+		tried := bandit.Tried(actions)
+		forceExplore := tried < minArms
+
+		var combo [3]int
+		// flipped is set when the coin flip decided the step.
+		exploring, flipped := false, false
+		switch {
+		case frozen:
+			slog.Debug("PERFORM BEST PERFOMING ACTION", "frozen", true)
+			combo = cfg.Space.FindBestSurvivalCombo(rng, actions, pruner)
+		case warmdown:
+			slog.Debug("PERFORM BEST PERFOMING ACTION", "warmdown", true)
+			combo = cfg.Space.FindBestSurvivalCombo(rng, actions, pruner)
+		case budgetSpent:
+			slog.Debug("PERFORM BEST PERFOMING ACTION", "explore budget spent", true)
+			combo = cfg.Space.FindBestSurvivalCombo(rng, actions, pruner)
+		case forceExplore:
+			slog.Debug("PERFORM FORCED EXPLORATION", "distinct combos", tried, "min arms", minArms)
+			exploring = true
+			combo = cfg.Space.RandomUntriedCombo(rng, actions, pruner)
+		case randomChance < Epsilon:
+			slog.Debug("PERFORM RANDOM ACTION")
+			flipped, exploring = true, true
+			if cfg.ExploreBudget > 0 {
+				// Draw only what the budget can still pay for, so the
+				// coin flip's outcome stands; it can pay for MinBatch.
+				combo, _ = cfg.Space.RandomComboUpTo(rng, cfg.ExploreBudget-exploreSpent, pruner)
+			} else {
+				combo = cfg.Space.RandomCombo(rng, pruner)
+			}
+		default:
+			slog.Debug("PERFORM BEST PERFOMING ACTION")
+			flipped = true
+			combo = cfg.Space.FindBestSurvivalCombo(rng, actions, pruner)
+		}
+
+		combo, ok := cfg.Space.Clamp(combo, mortiesCount)
+		if ok && exploring && cfg.ExploreBudget > 0 {
+			// The budget is charged with what is actually sent, so a
+			// forced exploration that would overshoot it exploits instead.
+			if exploreSpent+batch(combo) > cfg.ExploreBudget {
+				slog.Debug("PERFORM BEST PERFOMING ACTION", "over explore budget", true, "combo", combo)
+				exploring = false
+				combo, ok = cfg.Space.Clamp(cfg.Space.FindBestSurvivalCombo(rng, actions, pruner), mortiesCount)
+			}
+		}
+		if !ok {
+			stopErr = fmt.Errorf("step %d: %w: %d left", step, ErrNoCombo, mortiesCount)
+			break
+		}
+		// Only a coin flip whose outcome stood is audited.
+		if flipped {
+			eligible++
+			if exploring {
+				explored++
+			}
+		}
+		stepCtx, cancel := ctx, context.CancelFunc(func() {})
+		if cfg.StepTimeout > 0 {
+			stepCtx, cancel = context.WithTimeout(ctx, cfg.StepTimeout)
+		}
+		sent, err := c.SendMorties(stepCtx, combo)
+		var status client.Status
+		if err == nil {
+			status, err = readStatus(stepCtx, c)
+			if err != nil {
+				err = fmt.Errorf("fetching status: %w", err)
+			}
+		}
+		stepTimedOut := errors.Is(stepCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+		cancel()
+
+		if err != nil && stepTimedOut {
+			if cfg.StepTimeoutPolicy != StepTimeoutSkip {
+				stopErr = fmt.Errorf("step %d: %w after %s: %w", step, ErrStepTimeout, cfg.StepTimeout, err)
+				break
+			}
+			skipped++
+			if skipped >= maxSkippedSteps {
+				stopErr = fmt.Errorf("step %d: %w after %s, %d steps in a row: %w", step, ErrStepTimeout, cfg.StepTimeout, skipped, err)
+				break
+			}
+			// Some of the step's Morties may have been sent, so ask how
+			// many are left before carrying on.
+			slog.Warn("step timed out, skipping it", "step", step, "timeout", cfg.StepTimeout, "skipped_in_a_row", skipped, "error", err)
+			status, err = readStatus(ctx, c)
+			if err != nil {
+				stopErr = fmt.Errorf("step %d: fetching status after timeout: %w", step, err)
+				break
+			}
+			last = status
+			mortiesCount = int(status.MortiesInCitadel)
+			lastStep = step
+			beat(cfg.Heartbeat, step, mortiesCount, heartbeat.PhaseRunning)
+			continue
+		}
+		if err != nil {
+			// A portal request that timed out may or may not have sent its
+			// Morties, so the step cannot be recorded or safely repeated.
+			if client.IsTimeout(err) {
+				slog.Error("request timed out, stopping", "step", step, "error", err)
+			}
+			stopErr = fmt.Errorf("step %d: %w", step, err)
+			break
+		}
+		skipped = 0
+		// Only a completed step is charged to the exploration budget.
+		if exploring {
+			exploreSpent += batch(combo)
+		}
+		rate := survivalRate(sent)
+		slog.Debug("survival rate",
+			"combo", combo,
+			"rate with combo", rate,
+			"frozen", frozen,
+		)
+		// Only a step that finished in time is recorded.
+		if !frozen {
+			bandit.Update(actions, combo, rate)
+			pruned, readmitted := pruner.Prune(cfg.Space, actions)
+			for _, p := range pruned {
+				slog.Info("combo pruned", "step", step, "combo", p, "avg_survival_rate", actions[p].AvgSurvivalRate())
+			}
+			for _, p := range readmitted {
+				slog.Info("combo readmitted", "step", step, "combo", p, "avg_survival_rate", actions[p].AvgSurvivalRate())
+			}
+		}
+
+		last = status
+		slog.Info("Status",
+			"MortiesInCitadel", status.MortiesInCitadel,
+			"saved", status.Saved(),
+			"saved_rate", status.SavedRate(),
+		)
+
+		// Update mortyCount
+		mortiesCount = int(status.MortiesInCitadel)
+		lastStep = step
+		beat(cfg.Heartbeat, step, mortiesCount, heartbeat.PhaseRunning)
+		if cfg.afterStep != nil {
+			cfg.afterStep(step, actions)
+		}
+	}
+	beat(cfg.Heartbeat, lastStep, mortiesCount, heartbeat.PhaseFinished)
+	slog.Info("episode finished",
+		"saved", last.Saved(),
+		"lost", last.MortiesLost,
+		"remaining", last.MortiesInCitadel,
+		"saved_rate", last.SavedRate(),
+		"steps_taken", last.StepsTaken,
+	)
+	audit := bandit.AuditExploration(Epsilon, eligible, explored)
+	if audit.Mismatch {
+		slog.Warn("exploration rate does not match epsilon, probable bug",
+			"epsilon", Epsilon,
+			"eligible_steps", audit.Eligible,
+			"explored", audit.Explored,
+			"expected", audit.Expected,
+			"z", audit.Z,
+		)
+	} else {
+		slog.Info("exploration audit",
+			"eligible_steps", audit.Eligible,
+			"explored", audit.Explored,
+			"expected", audit.Expected,
+		)
+	}
+	if warmdownStep > 0 {
+		slog.Info("episode finished in warmdown", "warmdown_step", warmdownStep)
+	}
+	if cfg.PruneMargin > 0 {
+		slog.Info("pruning", "pruned_combos", pruner.Len(), "tried_combos", bandit.Tried(actions))
+	}
+	if cfg.Heartbeat.Degraded() {
+		slog.Warn("heartbeat was disabled during the run, watchdogs saw a stale file")
+	}
+	if cfg.ExploreBudget > 0 {
+		slog.Info("exploration budget",
+			"explore_spent", exploreSpent,
+			"explore_budget", cfg.ExploreBudget,
+			"spent_at_step", budgetStep,
+		)
+	}
+	if stats := c.BreakerStats(); stats.Trips > 0 {
+		slog.Info("circuit breaker",
+			"state", stats.State,
+			"trips", stats.Trips,
+			"time_open", stats.Open,
+		)
+	}
+	if cfg.MaxRequests > 0 {
+		slog.Info("requests used", "requests", c.Requests(), "max_requests", cfg.MaxRequests)
+	}
+	return last, stopErr
+}
+
+// startEpisode starts a new episode. A fresh episode always has Morties
+// waiting, so an empty Citadel means a bad token, an already finished episode
+// or a response we failed to read; the start is tried once more before
+// giving up with ErrNoMorties.
+func startEpisode(ctx context.Context, c *client.Client) (client.Status, error) {
+	start, err := c.StartEpisode(ctx)
+	if err != nil {
+		return start, fmt.Errorf("%w: %w", ErrStart, err)
+	}
+	if start.MortiesInCitadel > 0 {
+		return start, nil
+	}
+	slog.Warn("start reported no Morties in the Citadel, retrying once",
+		"status_message", start.StatusMessage,
+		"steps_taken", start.StepsTaken,
+	)
+	start, err = c.StartEpisode(ctx)
+	if err != nil {
+		return start, fmt.Errorf("%w: %w", ErrStart, err)
+	}
+	if start.MortiesInCitadel == 0 {
+		return start, fmt.Errorf("%w: %s", ErrNoMorties, start.StatusMessage)
+	}
+	return start, nil
+}
+
+// readStatus reads the status after a step. Failed requests are retried by
+// the client; an answer with no counts is read again here rather than taken
+// to mean the Citadel is empty, so a glitch cannot end the episode early.
+func readStatus(ctx context.Context, c *client.Client) (client.Status, error) {
+	status, err := c.Status(ctx)
+	for n := 1; err == nil && status.Phase() == client.PhaseUnknown; n++ {
+		if n > statusRereads {
+			return status, ErrEmptyStatus
+		}
+		wait := time.Duration(n) * statusRereadWait
+		slog.Warn("status has no counts, reading it again", "attempt", n, "wait", wait)
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-time.After(wait):
+		}
+		status, err = c.Status(ctx)
+	}
+	return status, err
+}
+
+// survivalRate is the fraction of the Morties in sent that survived, weighting
+// each planet by how many were sent there.
+func survivalRate(sent client.SendResult) float32 {
+	var survived, total int
+	for _, p := range sent.Planets {
+		total += p.Request.MortyCount
+		if p.Portal.Survived {
+			survived += p.Request.MortyCount
+		}
+	}
+	// Sending nobody has no rate; report 0 rather than NaN.
+	if total == 0 {
+		return 0
+	}
+	return float32(survived) / float32(total)
+}
+
+// batch returns the number of Morties combo sends.
+func batch(combo [3]int) int {
+	return combo[0] + combo[1] + combo[2]
+}
+
+// beat writes a heartbeat, logging rather than failing the run on error.
+func beat(hb *heartbeat.File, step, remaining int, phase string) {
+	if err := hb.Write(step, remaining, phase); err != nil {
+		slog.Error("heartbeat disabled, write failed, playing on without it", "error", err.Error())
+	}
+}
@@ -0,0 +1,543 @@
+package episode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"savemorty/internal/bandit"
+	"savemorty/internal/client"
+	"savemorty/internal/heartbeat"
+	"savemorty/internal/simulator"
+)
+
+// newSimClient serves a simulated game with the given number of Morties
+// through wrap, which may be nil, and returns a client for it.
+func newSimClient(t *testing.T, morties int, wrap func(http.Handler) http.Handler, opts ...client.Option) *client.Client {
+	t.Helper()
+	var h http.Handler = simulator.New(simulator.Config{Planets: [3]float64{0.9, 0.5, 0.2}, Morties: morties})
+	if wrap != nil {
+		h = wrap(h)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return client.NewClient(srv.URL, "token", &http.Client{Timeout: 5 * time.Second}, opts...)
+}
+
+// testConfig is a Config over the full combo space.
+func testConfig(t *testing.T) Config {
+	t.Helper()
+	space, err := bandit.NewSpace(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Config{Space: space, MinArms: 5}
+}
+
+// hangPortal holds the portal requests for which hang returns true until
+// the client gives up on them, counting the ones it held.
+func hangPortal(hang func(n int64) bool, hung *atomic.Int64) func(http.Handler) http.Handler {
+	var portals atomic.Int64
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/mortys/portal/" && hang(portals.Add(1)) {
+				hung.Add(1)
+				// The server only notices the client going away once
+				// the body has been read.
+				io.Copy(io.Discard, r.Body)
+				<-r.Context().Done()
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestStepTimeout(t *testing.T) {
+	const morties = 60
+	tests := []struct {
+		name     string
+		policy   string
+		hang     func(n int64) bool
+		wantErr  error
+		wantHung int64
+	}{
+		{
+			name:     "abort",
+			policy:   StepTimeoutAbort,
+			hang:     func(n int64) bool { return n == 2 },
+			wantErr:  ErrStepTimeout,
+			wantHung: 1,
+		},
+		{
+			name:     "skip",
+			policy:   StepTimeoutSkip,
+			hang:     func(n int64) bool { return n == 2 },
+			wantHung: 1,
+		},
+		{
+			name:     "skip gives up",
+			policy:   StepTimeoutSkip,
+			hang:     func(n int64) bool { return n >= 2 },
+			wantErr:  ErrStepTimeout,
+			wantHung: maxSkippedSteps,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hung atomic.Int64
+			c := newSimClient(t, morties, hangPortal(tt.hang, &hung))
+			cfg := testConfig(t)
+			cfg.StepTimeout = 100 * time.Millisecond
+			cfg.StepTimeoutPolicy = tt.policy
+
+			last, err := Run(context.Background(), c, cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run err = %v, want %v", err, tt.wantErr)
+			}
+			if got := hung.Load(); got != tt.wantHung {
+				t.Errorf("%d portal requests hung, want %d", got, tt.wantHung)
+			}
+			if tt.wantErr == nil && (last.MortiesInCitadel != 0 || last.Saved()+int(last.MortiesLost) != morties) {
+				t.Errorf("finished with %+v, want all %d Morties out of the Citadel", last, morties)
+			}
+		})
+	}
+}
+
+// countStarts counts the requests to the start endpoint.
+func countStarts(starts *atomic.Int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/mortys/start/" {
+				starts.Add(1)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestOnExisting(t *testing.T) {
+	const morties = 30
+	tests := []struct {
+		policy     string
+		wantErr    error
+		wantStarts int64
+	}{
+		{policy: OnExistingAbort, wantErr: ErrEpisodeInProgress, wantStarts: 1},
+		{policy: OnExistingContinue, wantStarts: 1},
+		{policy: OnExistingRestart, wantStarts: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			var starts atomic.Int64
+			c := newSimClient(t, morties, countStarts(&starts))
+			// Leave an episode in progress, one step in.
+			ctx := context.Background()
+			if _, err := c.StartEpisode(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.SendMorties(ctx, [3]int{3, 0, 0}); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := testConfig(t)
+			cfg.OnExisting = tt.policy
+			last, err := Run(ctx, c, cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run err = %v, want %v", err, tt.wantErr)
+			}
+			if got := starts.Load(); got != tt.wantStarts {
+				t.Errorf("start called %d times, want %d", got, tt.wantStarts)
+			}
+			switch tt.policy {
+			case OnExistingAbort:
+				// The episode is left as it was and reported.
+				if last.MortiesInCitadel != morties-3 || last.StepsTaken != 1 {
+					t.Errorf("last = %+v, want the episode in progress", last)
+				}
+			case OnExistingContinue, OnExistingRestart:
+				if last.MortiesInCitadel != 0 || last.Saved()+int(last.MortiesLost) != morties {
+					t.Errorf("finished with %+v, want all %d Morties out of the Citadel", last, morties)
+				}
+			}
+		})
+	}
+}
+
+func TestNoEpisodeInProgress(t *testing.T) {
+	// With nothing in progress every policy just starts one.
+	for _, policy := range []string{OnExistingAbort, OnExistingContinue, OnExistingRestart} {
+		var starts atomic.Int64
+		c := newSimClient(t, 10, countStarts(&starts))
+		cfg := testConfig(t)
+		cfg.OnExisting = policy
+		if _, err := Run(context.Background(), c, cfg); err != nil {
+			t.Fatalf("%s: Run: %v", policy, err)
+		}
+		if got := starts.Load(); got != 1 {
+			t.Errorf("%s: start called %d times, want 1", policy, got)
+		}
+	}
+}
+
+func TestFinalHeartbeatKeepsStep(t *testing.T) {
+	var statuses atomic.Int64
+	c := newSimClient(t, 30, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/mortys/status/" {
+				statuses.Add(1)
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	path := filepath.Join(t.TempDir(), "beat.json")
+	cfg := testConfig(t)
+	cfg.Heartbeat = heartbeat.New(path, "token")
+	if _, err := Run(context.Background(), c, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var beat heartbeat.Beat
+	if err := json.Unmarshal(b, &beat); err != nil {
+		t.Fatal(err)
+	}
+	// One status read looks for an episode in progress, then one per step.
+	if want := int(statuses.Load()) - 1; beat.Phase != heartbeat.PhaseFinished || beat.Step != want || beat.Remaining != 0 {
+		t.Errorf("final beat = %+v, want finished at step %d with none left", beat, want)
+	}
+}
+
+// logRecorder is a slog.Handler that keeps every record.
+type logRecorder struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (r *logRecorder) Enabled(context.Context, slog.Level) bool { return true }
+
+func (r *logRecorder) Handle(_ context.Context, rec slog.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, rec.Clone())
+	return nil
+}
+
+func (r *logRecorder) WithAttrs([]slog.Attr) slog.Handler { return r }
+func (r *logRecorder) WithGroup(string) slog.Handler      { return r }
+
+// recordLogs sends everything logged to a logRecorder until the test ends.
+func recordLogs(t *testing.T) *logRecorder {
+	t.Helper()
+	r := &logRecorder{}
+	prev := slog.Default()
+	slog.SetDefault(slog.New(r))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return r
+}
+
+// find returns the attributes of the first record logged with msg, or nil
+// if there was none.
+func (r *logRecorder) find(msg string) map[string]slog.Value {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rec := range r.records {
+		if rec.Message != msg {
+			continue
+		}
+		attrs := make(map[string]slog.Value)
+		rec.Attrs(func(a slog.Attr) bool {
+			attrs[a.Key] = a.Value
+			return true
+		})
+		return attrs
+	}
+	return nil
+}
+
+// messages returns the message of every record, in order.
+func (r *logRecorder) messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var msgs []string
+	for _, rec := range r.records {
+		msgs = append(msgs, rec.Message)
+	}
+	return msgs
+}
+
+// onlyCombo returns a space in which combo is the only one allowed.
+func onlyCombo(t *testing.T, combo [3]int) *bandit.Space {
+	t.Helper()
+	full, err := bandit.NewSpace(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exclude [][3]int
+	for _, c := range full.Combos() {
+		if c != combo {
+			exclude = append(exclude, c)
+		}
+	}
+	space, err := bandit.NewSpace(nil, exclude)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return space
+}
+
+func TestExploreBudgetSpentWhenNothingFits(t *testing.T) {
+	tests := []struct {
+		name      string
+		budget    int
+		wantSpent int64
+	}{
+		// Not even the one combo fits, so the budget is spent from the
+		// first step.
+		{name: "below the smallest batch", budget: 2, wantSpent: 0},
+		// One exploration leaves 1, less than the 3 the combo sends.
+		{name: "remainder below the smallest batch", budget: 4, wantSpent: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := recordLogs(t)
+			c := newSimClient(t, 90, nil)
+			cfg := Config{Space: onlyCombo(t, [3]int{1, 1, 1}), ExploreBudget: tt.budget}
+			if _, err := Run(context.Background(), c, cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			spent := logs.find("exploration budget spent, exploration disabled")
+			if spent == nil {
+				t.Fatal("budget never marked spent")
+			}
+			if got := spent["explore_spent"].Int64(); got != tt.wantSpent {
+				t.Errorf("marked spent after %d Morties, want %d", got, tt.wantSpent)
+			}
+			summary := logs.find("exploration budget")
+			if summary == nil || summary["spent_at_step"].Int64() != spent["step"].Int64() || summary["spent_at_step"].Int64() == 0 {
+				t.Errorf("summary = %v, want spent_at_step %v", summary, spent["step"])
+			}
+			if tt.wantSpent == 0 && spent["step"].Int64() != 1 {
+				t.Errorf("marked spent at step %v, want 1", spent["step"])
+			}
+		})
+	}
+}
+
+func TestExploreBudgetAuditsOnlyExplorations(t *testing.T) {
+	logs := recordLogs(t)
+	c := newSimClient(t, 150, nil)
+	cfg := testConfig(t)
+	// Every combo sends at least 3, so at most one exploration fits.
+	cfg.MinArms = 0
+	cfg.ExploreBudget = 3
+	if _, err := Run(context.Background(), c, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	audit := logs.find("exploration audit")
+	if audit == nil {
+		audit = logs.find("exploration rate does not match epsilon, probable bug")
+	}
+	budget := logs.find("exploration budget")
+	if audit == nil || budget == nil {
+		t.Fatalf("audit %v, budget %v, want both logged", audit, budget)
+	}
+	explored, spent := audit["explored"].Int64(), budget["explore_spent"].Int64()
+	if spent > int64(cfg.ExploreBudget) {
+		t.Errorf("spent %d on exploration, over the budget of %d", spent, cfg.ExploreBudget)
+	}
+	// Each counted exploration was sent, and sent at least 3 Morties.
+	if explored*3 > spent {
+		t.Errorf("audit counted %d explorations but only %d Morties were spent on them", explored, spent)
+	}
+}
+
+func TestWarmdownOnContinuedEpisode(t *testing.T) {
+	const morties = 100
+	logs := recordLogs(t)
+	c := newSimClient(t, morties, nil)
+	// Leave an episode in progress with 19 of its 100 Morties left.
+	ctx := context.Background()
+	if _, err := c.StartEpisode(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for range 9 {
+		if _, err := c.SendMorties(ctx, [3]int{3, 3, 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := testConfig(t)
+	cfg.OnExisting = OnExistingContinue
+	// Half of the episode's 100 is 50, so 19 left is already below it.
+	// Taken of the 19 left instead, warmdown would wait for 9.
+	if err := cfg.Warmdown.Set("50%"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Run(ctx, c, cfg); err != nil {
+		t.Fatal(err)
+	}
+	warmdown := logs.find("warmdown started, exploration disabled")
+	if warmdown == nil || warmdown["step"].Int64() != 1 || warmdown["remaining"].Int64() != morties-81 {
+		t.Errorf("warmdown = %v, want it from step 1 with %d left", warmdown, morties-81)
+	}
+}
+
+// snapshot renders every combo's statistics exactly, observations included.
+func snapshot(actions map[[3]int]*bandit.Action) map[[3]int]string {
+	snap := make(map[[3]int]string, len(actions))
+	for combo, a := range actions {
+		snap[combo] = fmt.Sprintf("%v %b", *a, math.Float32bits(a.AvgSurvivalRate()))
+	}
+	return snap
+}
+
+func TestFreezeKeepsStatsIdentical(t *testing.T) {
+	const freezeAt = 6
+	c := newSimClient(t, 120, nil)
+	cfg := testConfig(t)
+	cfg.FreezeAfterStep = freezeAt
+	var frozen map[[3]int]string
+	var frozenSteps int
+	cfg.afterStep = func(step int, actions map[[3]int]*bandit.Action) {
+		switch {
+		case step < freezeAt:
+		case step == freezeAt:
+			frozen = snapshot(actions)
+		default:
+			frozenSteps++
+			if got := snapshot(actions); !maps.Equal(got, frozen) {
+				t.Fatalf("step %d changed the frozen statistics:\ngot  %v\nwant %v", step, got, frozen)
+			}
+		}
+	}
+	last, err := Run(context.Background(), c, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frozen == nil || frozenSteps == 0 {
+		t.Fatalf("%d steps after the freeze, want some", frozenSteps)
+	}
+	// Frozen steps still play and count toward the episode.
+	if last.MortiesInCitadel != 0 || last.Saved()+int(last.MortiesLost) != 120 {
+		t.Errorf("finished with %+v, want all 120 Morties out of the Citadel", last)
+	}
+}
+
+// emptyStatuses answers the status reads for which empty returns true with
+// all-zero counts, as a glitching server might, counting every status read.
+func emptyStatuses(empty func(n int64) bool, reads *atomic.Int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/mortys/status/" && empty(reads.Add(1)) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"morties_in_citadel":0,"morties_on_planet_jessica":0,"morties_lost":0,"steps_taken":0,"status_message":""}`))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestEmptyStatus(t *testing.T) {
+	prev := statusRereadWait
+	statusRereadWait = time.Millisecond
+	t.Cleanup(func() { statusRereadWait = prev })
+
+	const morties = 30
+	tests := []struct {
+		name      string
+		empty     func(n int64) bool
+		wantErr   error
+		wantReads int64
+	}{
+		{
+			// Read 1 looks for an episode in progress; the first step's
+			// read and every reread after it come back empty.
+			name:      "never recovers",
+			empty:     func(n int64) bool { return n >= 2 },
+			wantErr:   ErrEmptyStatus,
+			wantReads: 2 + statusRereads,
+		},
+		{
+			name:  "recovers on the last reread",
+			empty: func(n int64) bool { return n >= 2 && n < 2+statusRereads },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reads atomic.Int64
+			c := newSimClient(t, morties, emptyStatuses(tt.empty, &reads))
+			last, err := Run(context.Background(), c, testConfig(t))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if got := reads.Load(); got != tt.wantReads {
+					t.Errorf("status read %d times, want %d", got, tt.wantReads)
+				}
+				// The counts reported are the last real ones, not zeros.
+				if last.MortiesInCitadel != morties {
+					t.Errorf("last = %+v, want the %d Morties from the start", last, morties)
+				}
+				return
+			}
+			if last.MortiesInCitadel != 0 || last.Saved()+int(last.MortiesLost) != morties {
+				t.Errorf("finished with %+v, want all %d Morties out of the Citadel", last, morties)
+			}
+		})
+	}
+}
+
+func TestMinArmsThenExploit(t *testing.T) {
+	for _, minArms := range []int{1, 5, 12} {
+		t.Run(fmt.Sprint(minArms), func(t *testing.T) {
+			logs := recordLogs(t)
+			c := newSimClient(t, 300, nil)
+			cfg := testConfig(t)
+			cfg.MinArms = minArms
+			cfg.Rand = rand.New(rand.NewPCG(1, uint64(minArms)))
+			if _, err := Run(context.Background(), c, cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			var decisions []string
+			for _, msg := range logs.messages() {
+				switch msg {
+				case "PERFORM FORCED EXPLORATION", "PERFORM RANDOM ACTION", "PERFORM BEST PERFOMING ACTION":
+					decisions = append(decisions, msg)
+				}
+			}
+			if len(decisions) <= minArms {
+				t.Fatalf("only %d steps", len(decisions))
+			}
+			// The 2,2,2 prior is not an observation, so it does not
+			// shorten the forced exploration by one.
+			for i, d := range decisions {
+				if forced := d == "PERFORM FORCED EXPLORATION"; forced != (i < minArms) {
+					t.Fatalf("step %d: %s, want forced exploration for exactly the first %d steps", i+1, d, minArms)
+				}
+			}
+		})
+	}
+}
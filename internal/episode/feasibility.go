@@ -0,0 +1,38 @@
+package episode
+
+import "errors"
+
+// ErrInfeasible is returned by Run in strict mode when the stop conditions
+// cannot let the episode finish even in the best case.
+var ErrInfeasible = errors.New("stop conditions make finishing the episode impossible")
+
+// Feasibility compares the fewest steps an episode could finish in with the
+// number of steps its stop conditions allow.
+type Feasibility struct {
+	Morties  int
+	MaxBatch int
+	// StepsNeeded is the step count if every step sends MaxBatch Morties.
+	StepsNeeded int
+	// StepsAllowed is how many steps fit under the request cap, or -1 when
+	// there is no cap.
+	StepsAllowed int
+}
+
+// CheckFeasibility works out whether morties can all be sent in batches of at
+// most maxBatch before the client has made maxRequests requests, given that
+// requestsUsed have been made already. A maxRequests of zero means no cap.
+func CheckFeasibility(morties, maxBatch int, maxRequests, requestsUsed int64) Feasibility {
+	f := Feasibility{Morties: morties, MaxBatch: maxBatch, StepsAllowed: -1}
+	if maxBatch > 0 {
+		f.StepsNeeded = (morties + maxBatch - 1) / maxBatch
+	}
+	if maxRequests > 0 {
+		f.StepsAllowed = max(0, int((maxRequests-requestsUsed)/requestsPerStep))
+	}
+	return f
+}
+
+// Feasible reports whether the best case fits in the allowed steps.
+func (f Feasibility) Feasible() bool {
+	return f.StepsAllowed < 0 || f.StepsNeeded <= f.StepsAllowed
+}
@@ -0,0 +1,36 @@
+package episode
+
+import "testing"
+
+func TestCheckFeasibility(t *testing.T) {
+	tests := []struct {
+		name         string
+		morties      int
+		maxBatch     int
+		maxRequests  int64
+		requestsUsed int64
+		want         Feasibility
+		wantFeasible bool
+	}{
+		{name: "no cap", morties: 1000, maxBatch: 9, want: Feasibility{1000, 9, 112, -1}, wantFeasible: true},
+		{name: "feasible", morties: 1000, maxBatch: 9, maxRequests: 1000, want: Feasibility{1000, 9, 112, 250}, wantFeasible: true},
+		{name: "exactly enough", morties: 1000, maxBatch: 9, maxRequests: 448, want: Feasibility{1000, 9, 112, 112}, wantFeasible: true},
+		{name: "one step short", morties: 1000, maxBatch: 9, maxRequests: 447, want: Feasibility{1000, 9, 112, 111}},
+		{name: "infeasible", morties: 1000, maxBatch: 3, maxRequests: 200, want: Feasibility{1000, 3, 334, 50}},
+		// The status check and the start have used two requests already.
+		{name: "budget partly used", morties: 18, maxBatch: 9, maxRequests: 10, requestsUsed: 2, want: Feasibility{18, 9, 2, 2}, wantFeasible: true},
+		{name: "budget used up", morties: 18, maxBatch: 9, maxRequests: 10, requestsUsed: 12, want: Feasibility{18, 9, 2, 0}},
+		{name: "no Morties left", maxBatch: 9, maxRequests: 4, requestsUsed: 4, want: Feasibility{0, 9, 0, 0}, wantFeasible: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CheckFeasibility(tt.morties, tt.maxBatch, tt.maxRequests, tt.requestsUsed)
+			if got != tt.want {
+				t.Errorf("CheckFeasibility = %+v, want %+v", got, tt.want)
+			}
+			if got.Feasible() != tt.wantFeasible {
+				t.Errorf("Feasible = %v, want %v", got.Feasible(), tt.wantFeasible)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package episode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Threshold is a Morty count given either as an absolute number ("30") or as
+// a percentage of the Morties the episode started with ("3%"). It implements
+// flag.Value. The zero Threshold is never reached.
+type Threshold struct {
+	Count   int
+	Percent float64
+}
+
+func (t *Threshold) String() string {
+	if t.Percent > 0 {
+		return strconv.FormatFloat(t.Percent, 'f', -1, 64) + "%"
+	}
+	return strconv.Itoa(t.Count)
+}
+
+func (t *Threshold) Set(s string) error {
+	s = strings.TrimSpace(s)
+	if p, ok := strings.CutSuffix(s, "%"); ok {
+		f, err := strconv.ParseFloat(p, 64)
+		if err != nil || f < 0 || f > 100 {
+			return fmt.Errorf("invalid percentage %q", s)
+		}
+		*t = Threshold{Percent: f}
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid Morty count %q", s)
+	}
+	*t = Threshold{Count: n}
+	return nil
+}
+
+// Below reports whether remaining has dropped under the threshold for an
+// episode that started with initial Morties.
+func (t Threshold) Below(remaining, initial int) bool {
+	if t.Percent > 0 {
+		return float64(remaining) < t.Percent/100*float64(initial)
+	}
+	return remaining < t.Count
+}
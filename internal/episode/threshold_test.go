@@ -0,0 +1,126 @@
+package episode
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"savemorty/internal/client"
+)
+
+func TestThresholdSet(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Threshold
+		wantErr bool
+	}{
+		{in: "5", want: Threshold{Count: 5}},
+		{in: " 30 ", want: Threshold{Count: 30}},
+		{in: "0", want: Threshold{}},
+		{in: "5%", want: Threshold{Percent: 5}},
+		{in: "2.5%", want: Threshold{Percent: 2.5}},
+		{in: "100%", want: Threshold{Percent: 100}},
+		{in: "-1", wantErr: true},
+		{in: "-1%", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "abc%", wantErr: true},
+		{in: "150%", wantErr: true},
+		{in: "5.5", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		var got Threshold
+		err := got.Set(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Set(%q) err = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Set(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if !tt.wantErr && tt.in != " 30 " && got.String() != tt.in {
+			t.Errorf("Set(%q).String() = %q", tt.in, got.String())
+		}
+	}
+}
+
+func TestThresholdBelowBoundary(t *testing.T) {
+	tests := []struct {
+		threshold string
+		initial   int
+		// at is the remaining count the threshold works out to.
+		at int
+	}{
+		{threshold: "30", initial: 1000, at: 30},
+		{threshold: "3%", initial: 1000, at: 30},
+		{threshold: "50%", initial: 100, at: 50},
+		{threshold: "1", initial: 10, at: 1},
+	}
+	for _, tt := range tests {
+		var th Threshold
+		if err := th.Set(tt.threshold); err != nil {
+			t.Fatal(err)
+		}
+		// Below is strict: at the threshold exploration carries on.
+		for remaining, want := range map[int]bool{tt.at - 1: true, tt.at: false, tt.at + 1: false} {
+			if got := th.Below(remaining, tt.initial); got != want {
+				t.Errorf("%s of %d: Below(%d) = %v, want %v", tt.threshold, tt.initial, remaining, got, want)
+			}
+		}
+	}
+
+	// The zero Threshold is never reached.
+	var zero Threshold
+	if zero.Below(0, 1000) {
+		t.Error("zero Threshold reached")
+	}
+}
+
+func TestWarmdownStopsExplorationAtThreshold(t *testing.T) {
+	const morties = 120
+	for _, threshold := range []string{"10", "40", "25%"} {
+		t.Run(threshold, func(t *testing.T) {
+			logs := recordLogs(t)
+			c := newSimClient(t, morties, nil)
+			cfg := testConfig(t)
+			if err := cfg.Warmdown.Set(threshold); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Run(context.Background(), c, cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			// The status read just before warmdown started was the first
+			// one below the threshold.
+			prev, left := -1, morties
+			for _, rec := range logs.records {
+				if rec.Message == "warmdown started, exploration disabled" {
+					break
+				}
+				if rec.Message == "Status" {
+					rec.Attrs(func(a slog.Attr) bool {
+						if a.Key == "MortiesInCitadel" {
+							prev, left = left, int(a.Value.Any().(client.FlexInt))
+						}
+						return true
+					})
+				}
+			}
+			if !cfg.Warmdown.Below(left, morties) || (prev >= 0 && cfg.Warmdown.Below(prev, morties)) {
+				t.Errorf("warmdown started with %d left after %d, want the first count below %s", left, prev, threshold)
+			}
+			// No step explored or flipped a coin once it had started.
+			msgs := logs.messages()
+			at := slices.Index(msgs, "warmdown started, exploration disabled")
+			if at < 0 {
+				t.Fatal("warmdown never started")
+			}
+			for _, msg := range msgs[at:] {
+				if msg == "PERFORM RANDOM ACTION" || msg == "PERFORM FORCED EXPLORATION" {
+					t.Fatalf("%q logged after warmdown started", msg)
+				}
+			}
+		})
+	}
+}
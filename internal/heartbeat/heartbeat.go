@@ -0,0 +1,147 @@
+// Package heartbeat writes a small JSON file after every step so an external
+// watchdog can tell a wedged run from a slow one by the file's age.
+package heartbeat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrRunning is returned by CheckCollision when a fresh heartbeat from the
+// same token suggests another instance is still playing.
+var ErrRunning = errors.New("another run with this token has a fresh heartbeat")
+
+// Phases reported in Beat.Phase.
+const (
+	PhaseStarting = "starting"
+	PhaseRunning  = "running"
+	PhaseFinished = "finished"
+)
+
+// Beat is the JSON blob stored in the heartbeat file.
+type Beat struct {
+	Timestamp time.Time `json:"timestamp"`
+	Step      int       `json:"step"`
+	Remaining int       `json:"remaining"`
+	Phase     string    `json:"phase"`
+	// Token is a short hash of the auth header, never the header itself.
+	Token string `json:"token"`
+}
+
+// File is a heartbeat file. A nil *File ignores every call so callers do not
+// need to check whether heartbeats are enabled.
+//
+// The first failed write disables the file for the rest of the run, so a
+// read-only or full disk costs one warning instead of one per step.
+type File struct {
+	path  string
+	token string
+	// degraded is set once a write or probe has failed.
+	degraded bool
+	// wrap, if set, wraps the temporary file each beat is written to.
+	// Tests use it to stand in for a full disk.
+	wrap func(io.Writer) io.Writer
+}
+
+// New returns a heartbeat file at path for the given auth header.
+func New(path, authHeader string) *File {
+	sum := sha256.Sum256([]byte(authHeader))
+	return &File{path: path, token: hex.EncodeToString(sum[:8])}
+}
+
+// Write replaces the heartbeat file with the current state. The new contents
+// are written to a temporary file and renamed into place so a watchdog never
+// reads a partial blob.
+func (f *File) Write(step, remaining int, phase string) error {
+	if f == nil || f.degraded {
+		return nil
+	}
+	if err := f.write(step, remaining, phase); err != nil {
+		f.degraded = true
+		return err
+	}
+	return nil
+}
+
+func (f *File) write(step, remaining int, phase string) error {
+	b, err := json.Marshal(Beat{
+		Timestamp: time.Now().UTC(),
+		Step:      step,
+		Remaining: remaining,
+		Phase:     phase,
+		Token:     f.token,
+	})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	var w io.Writer = tmp
+	if f.wrap != nil {
+		w = f.wrap(tmp)
+	}
+	if _, err := w.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// Probe checks that a file can be created next to the heartbeat file, and
+// disables heartbeats if not.
+func (f *File) Probe() error {
+	if f == nil {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".probe*")
+	if err != nil {
+		f.degraded = true
+		return err
+	}
+	tmp.Close()
+	os.Remove(tmp.Name())
+	return nil
+}
+
+// Degraded reports whether heartbeats were disabled by a failed write or
+// probe.
+func (f *File) Degraded() bool {
+	return f != nil && f.degraded
+}
+
+// CheckCollision returns ErrRunning if the heartbeat file was written for the
+// same token less than maxAge ago and the run it describes has not finished.
+// A missing or unreadable file is not a collision.
+func (f *File) CheckCollision(maxAge time.Duration) error {
+	if f == nil {
+		return nil
+	}
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil
+	}
+	var beat Beat
+	if err := json.Unmarshal(b, &beat); err != nil {
+		return nil
+	}
+	if beat.Token != f.token || beat.Phase == PhaseFinished {
+		return nil
+	}
+	if age := time.Since(beat.Timestamp); age < maxAge {
+		return fmt.Errorf("%w: %s written %s ago at step %d", ErrRunning, f.path, age.Round(time.Second), beat.Step)
+	}
+	return nil
+}
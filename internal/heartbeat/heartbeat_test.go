@@ -0,0 +1,225 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeBeat writes beat to path as Write would have.
+func writeBeat(t *testing.T, path string, beat Beat) {
+	t.Helper()
+	b, err := json.Marshal(beat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckCollision(t *testing.T) {
+	const maxAge = time.Minute
+	token := New("", "tok").token
+	tests := []struct {
+		name    string
+		beat    *Beat
+		raw     string
+		wantErr error
+	}{
+		{
+			name:    "fresh",
+			beat:    &Beat{Timestamp: time.Now().Add(-maxAge / 2), Step: 7, Phase: PhaseRunning, Token: token},
+			wantErr: ErrRunning,
+		},
+		{
+			name:    "fresh while starting",
+			beat:    &Beat{Timestamp: time.Now(), Phase: PhaseStarting, Token: token},
+			wantErr: ErrRunning,
+		},
+		{
+			// A run that wedged stopped rewriting the file.
+			name: "stale",
+			beat: &Beat{Timestamp: time.Now().Add(-2 * maxAge), Step: 7, Phase: PhaseRunning, Token: token},
+		},
+		{
+			name: "other token",
+			beat: &Beat{Timestamp: time.Now(), Step: 7, Phase: PhaseRunning, Token: New("", "other").token},
+		},
+		{
+			name: "finished",
+			beat: &Beat{Timestamp: time.Now(), Step: 7, Phase: PhaseFinished, Token: token},
+		},
+		{name: "missing"},
+		{name: "garbage", raw: "{not json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "beat.json")
+			switch {
+			case tt.beat != nil:
+				writeBeat(t, path, *tt.beat)
+			case tt.raw != "":
+				if err := os.WriteFile(path, []byte(tt.raw), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := New(path, "tok").CheckCollision(maxAge); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckCollision = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteThenCheckCollision(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beat.json")
+	f := New(path, "tok")
+	if err := f.Write(3, 40, PhaseRunning); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var beat Beat
+	if err := json.Unmarshal(b, &beat); err != nil {
+		t.Fatal(err)
+	}
+	if beat.Step != 3 || beat.Remaining != 40 || beat.Phase != PhaseRunning || beat.Token != f.token {
+		t.Errorf("beat = %+v", beat)
+	}
+	if time.Since(beat.Timestamp) > time.Minute {
+		t.Errorf("beat written at %v, want now", beat.Timestamp)
+	}
+
+	// A second run with the same token sees the beat as fresh, then as
+	// stale once it is older than the limit.
+	if err := New(path, "tok").CheckCollision(time.Minute); !errors.Is(err, ErrRunning) {
+		t.Errorf("fresh beat: CheckCollision = %v, want %v", err, ErrRunning)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := New(path, "tok").CheckCollision(5 * time.Millisecond); err != nil {
+		t.Errorf("stale beat: CheckCollision = %v, want nil", err)
+	}
+
+	if err := f.Write(9, 0, PhaseFinished); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(path, "tok").CheckCollision(time.Minute); err != nil {
+		t.Errorf("finished beat: CheckCollision = %v, want nil", err)
+	}
+}
+
+func TestNilFile(t *testing.T) {
+	var f *File
+	if err := f.Write(1, 1, PhaseRunning); err != nil {
+		t.Errorf("Write = %v", err)
+	}
+	if err := f.Probe(); err != nil {
+		t.Errorf("Probe = %v", err)
+	}
+	if err := f.CheckCollision(time.Minute); err != nil {
+		t.Errorf("CheckCollision = %v", err)
+	}
+	if f.Degraded() {
+		t.Error("nil File is degraded")
+	}
+}
+
+func TestProbeUnwritableDir(t *testing.T) {
+	tests := []struct {
+		name string
+		dir  func(t *testing.T) string
+	}{
+		{
+			name: "read-only",
+			dir: func(t *testing.T) string {
+				if os.Geteuid() == 0 {
+					t.Skip("root can write to a read-only directory")
+				}
+				dir := t.TempDir()
+				if err := os.Chmod(dir, 0o500); err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { os.Chmod(dir, 0o700) })
+				return dir
+			},
+		},
+		{
+			name: "not a directory",
+			dir: func(t *testing.T) string {
+				path := filepath.Join(t.TempDir(), "file")
+				if err := os.WriteFile(path, nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return path
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tt.dir(t), "beat.json")
+			f := New(path, "tok")
+			if err := f.Probe(); err == nil {
+				t.Fatal("Probe succeeded")
+			}
+			if !f.Degraded() {
+				t.Error("not degraded after a failed probe")
+			}
+			// Later writes are dropped quietly instead of failing every step.
+			if err := f.Write(1, 10, PhaseRunning); err != nil {
+				t.Errorf("Write after a failed probe = %v, want nil", err)
+			}
+		})
+	}
+}
+
+// errWriter fails every write, as a full disk would.
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, errors.New("no space left on device") }
+
+func TestWriteFailureDegrades(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "beat.json")
+	f := New(path, "tok")
+	if err := f.Probe(); err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if err := f.Write(1, 10, PhaseRunning); err != nil || f.Degraded() {
+		t.Fatalf("Write = %v, Degraded = %v before the disk filled", err, f.Degraded())
+	}
+
+	f.wrap = func(io.Writer) io.Writer { return errWriter{} }
+	if err := f.Write(2, 9, PhaseRunning); err == nil {
+		t.Fatal("Write succeeded on a full disk")
+	}
+	if !f.Degraded() {
+		t.Error("not degraded after a failed write")
+	}
+	// Only the first failure is reported, and nothing more is written.
+	f.wrap = nil
+	if err := f.Write(3, 8, PhaseRunning); err != nil {
+		t.Errorf("second Write = %v, want nil", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var beat Beat
+	if err := json.Unmarshal(b, &beat); err != nil || beat.Step != 1 {
+		t.Errorf("file holds %s, want the beat from step 1", b)
+	}
+	// The failed write left no temporary file behind.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the heartbeat", len(entries))
+	}
+}
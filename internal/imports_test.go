@@ -0,0 +1,81 @@
+// Package internal_test checks that dependencies between the internal
+// packages only point one way.
+package internal_test
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const module = "savemorty/"
+
+// allowed lists, for each internal package, the module packages its non-test
+// files may import. client and bandit stand alone so either can be reused or
+// tested without the other; the simulator stands alone so it cannot share a
+// bug with the client it is meant to check.
+var allowed = map[string][]string{
+	"bandit":    nil,
+	"client":    nil,
+	"heartbeat": nil,
+	"simulator": nil,
+	"episode":   {"internal/bandit", "internal/client", "internal/heartbeat"},
+}
+
+func TestImportDirection(t *testing.T) {
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		pkg := e.Name()
+		want, ok := allowed[pkg]
+		if !ok {
+			t.Errorf("internal/%s has no entry in allowed; add one", pkg)
+			continue
+		}
+		for _, imp := range imports(t, pkg) {
+			if !slices.Contains(want, imp) {
+				t.Errorf("internal/%s imports %s, which it must not", pkg, imp)
+			}
+		}
+	}
+}
+
+// imports returns the module packages imported by the non-test files in dir.
+func imports(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, parser.ImportsOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, spec := range f.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rest, ok := strings.CutPrefix(path, module); ok {
+				out = append(out, rest)
+			}
+		}
+	}
+	return out
+}
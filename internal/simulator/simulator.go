@@ -0,0 +1,235 @@
+// Package simulator serves a local imitation of the challenge API so solvers
+// can be exercised without a token or network access. Every Authorization
+// header gets its own game, keyed by a hash of the header so tokens are never
+// kept or shown.
+package simulator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+)
+
+// Config describes the simulated game.
+type Config struct {
+	// Planets is the chance a batch sent to each planet survives.
+	Planets [3]float64
+	// Morties is how many Morties start in the Citadel.
+	Morties int
+	// FailRate is the fraction of requests answered with a 500 before
+	// touching any game state.
+	FailRate float64
+	// Drift is how far each planet's survival chance may wander, up or
+	// down at random, after every portal request of a game. Zero keeps the
+	// chances fixed.
+	Drift float64
+}
+
+// status mirrors the challenge's status and start responses.
+type status struct {
+	MortiesInCitadel       int    `json:"morties_in_citadel"`
+	MortiesOnPlanetJessica int    `json:"morties_on_planet_jessica"`
+	MortiesLost            int    `json:"morties_lost"`
+	StepsTaken             int    `json:"steps_taken"`
+	StatusMessage          string `json:"status_message"`
+}
+
+// portal mirrors the challenge's portal response.
+type portal struct {
+	MortiesSent            int  `json:"morties_sent"`
+	Survived               bool `json:"survived"`
+	MortiesInCitadel       int  `json:"morties_in_citadel"`
+	MortiesOnPlanetJessica int  `json:"morties_on_planet_jessica"`
+	MortiesLost            int  `json:"morties_lost"`
+	StepsTaken             int  `json:"steps_taken"`
+}
+
+// session is the game state for one token.
+type session struct {
+	citadel int
+	jessica int
+	lost    int
+	steps   int
+	// planets is the game's current survival chance for each planet.
+	planets [3]float64
+}
+
+// drift moves every planet's survival chance by up to d either way, keeping
+// it a probability.
+func (s *session) drift(d float64) {
+	for p := range s.planets {
+		s.planets[p] = min(1, max(0, s.planets[p]+d*(2*rand.Float64()-1)))
+	}
+}
+
+// sessionState is a session as /admin/sessions dumps it.
+type sessionState struct {
+	status
+	Planets [3]float64 `json:"planets"`
+}
+
+func (s *session) status() status {
+	msg := "Episode in progress"
+	if s.citadel == 0 {
+		msg = "Episode completed"
+	}
+	return status{
+		MortiesInCitadel:       s.citadel,
+		MortiesOnPlanetJessica: s.jessica,
+		MortiesLost:            s.lost,
+		StepsTaken:             s.steps,
+		StatusMessage:          msg,
+	}
+}
+
+// Server is an http.Handler serving the simulated API.
+type Server struct {
+	cfg Config
+	mux *http.ServeMux
+
+	mu sync.Mutex
+	// sessions is keyed by sessionKey of the Authorization header.
+	sessions map[string]*session
+}
+
+// sessionKey identifies the session for an Authorization header without
+// keeping the header itself, the same way heartbeat files do.
+func sessionKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// New returns a Server playing the game described by cfg.
+func New(cfg Config) *Server {
+	s := &Server{
+		cfg:      cfg,
+		mux:      http.NewServeMux(),
+		sessions: make(map[string]*session),
+	}
+	s.mux.HandleFunc("POST /api/mortys/start/", s.authed(s.handleStart))
+	s.mux.HandleFunc("POST /api/mortys/portal/", s.authed(s.handlePortal))
+	s.mux.HandleFunc("GET /api/mortys/status/", s.authed(s.handleStatus))
+	s.mux.HandleFunc("POST /reset", s.authed(s.handleReset))
+	s.mux.HandleFunc("GET /admin/sessions", s.handleSessions)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("simulator request", "method", r.Method, "path", r.URL.Path)
+	s.mux.ServeHTTP(w, r)
+}
+
+// authed rejects requests without an Authorization header, injects faults
+// and passes the session key for the header on to the handler.
+func (s *Server) authed(h func(w http.ResponseWriter, r *http.Request, key string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("Authorization")
+		if token == "" {
+			writeError(w, http.StatusUnauthorized, "missing Authorization header")
+			return
+		}
+		if s.cfg.FailRate > 0 && rand.Float64() < s.cfg.FailRate {
+			writeError(w, http.StatusInternalServerError, "injected fault")
+			return
+		}
+		h(w, r, sessionKey(token))
+	}
+}
+
+func (s *Server) handleStart(w http.ResponseWriter, r *http.Request, key string) {
+	s.mu.Lock()
+	sess := &session{citadel: s.cfg.Morties, planets: s.cfg.Planets}
+	s.sessions[key] = sess
+	st := sess.status()
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (s *Server) handlePortal(w http.ResponseWriter, r *http.Request, key string) {
+	var req struct {
+		Planet     int `json:"planet"`
+		MortyCount int `json:"morty_count"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if req.Planet < 0 || req.Planet >= len(s.cfg.Planets) {
+		writeError(w, http.StatusBadRequest, "planet must be 0, 1 or 2")
+		return
+	}
+	if req.MortyCount < 1 || req.MortyCount > 3 {
+		writeError(w, http.StatusBadRequest, "morty_count must be between 1 and 3")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[key]
+	if !ok || sess.citadel == 0 {
+		writeError(w, http.StatusBadRequest, "episode not active")
+		return
+	}
+	sent := min(req.MortyCount, sess.citadel)
+	survived := rand.Float64() < sess.planets[req.Planet]
+	sess.citadel -= sent
+	if survived {
+		sess.jessica += sent
+	} else {
+		sess.lost += sent
+	}
+	sess.steps++
+	sess.drift(s.cfg.Drift)
+	writeJSON(w, http.StatusOK, portal{
+		MortiesSent:            sent,
+		Survived:               survived,
+		MortiesInCitadel:       sess.citadel,
+		MortiesOnPlanetJessica: sess.jessica,
+		MortiesLost:            sess.lost,
+		StepsTaken:             sess.steps,
+	})
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request, key string) {
+	s.mu.Lock()
+	sess, ok := s.sessions[key]
+	if !ok {
+		sess = &session{}
+	}
+	st := sess.status()
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (s *Server) handleReset(w http.ResponseWriter, r *http.Request, key string) {
+	s.mu.Lock()
+	delete(s.sessions, key)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSessions dumps every session keyed by the hash of its token, with
+// the planets' current survival chances.
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	out := make(map[string]sessionState, len(s.sessions))
+	for key, sess := range s.sessions {
+		out[key] = sessionState{status: sess.status(), Planets: sess.planets}
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, out)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
@@ -0,0 +1,224 @@
+package simulator_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"savemorty/internal/bandit"
+	"savemorty/internal/client"
+	"savemorty/internal/episode"
+	"savemorty/internal/simulator"
+)
+
+// serve starts the simulator on a localhost port.
+func serve(t *testing.T, cfg simulator.Config) string {
+	t.Helper()
+	srv := httptest.NewServer(simulator.New(cfg))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// play runs the solver against the simulator at base with the given token.
+func play(base, token string) (client.Status, error) {
+	space, err := bandit.NewSpace(nil, nil)
+	if err != nil {
+		return client.Status{}, err
+	}
+	c := client.NewClient(base, token, &http.Client{Timeout: 5 * time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return episode.Run(ctx, c, episode.Config{Space: space, MinArms: 5, OnExisting: episode.OnExistingRestart})
+}
+
+// sessions fetches the admin dump.
+func sessions(t *testing.T, base string) (map[string]json.RawMessage, string) {
+	t.Helper()
+	res, err := http.Get(base + "/admin/sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var raw json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&raw); err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out, string(raw)
+}
+
+func TestSolverPlaysToCompletion(t *testing.T) {
+	const morties = 200
+	base := serve(t, simulator.Config{Planets: [3]float64{0.9, 0.5, 0.2}, Morties: morties})
+
+	// Two tokens play at once, each in its own game.
+	tokens := []string{"token-a", "token-b"}
+	results := make([]client.Status, len(tokens))
+	errs := make([]error, len(tokens))
+	var wg sync.WaitGroup
+	for i, token := range tokens {
+		wg.Go(func() { results[i], errs[i] = play(base, token) })
+	}
+	wg.Wait()
+
+	for i, last := range results {
+		if errs[i] != nil {
+			t.Errorf("Run with %s: %v", tokens[i], errs[i])
+			continue
+		}
+		if last.MortiesInCitadel != 0 || last.Saved()+int(last.MortiesLost) != morties {
+			t.Errorf("%s finished with %+v, want all %d Morties out of the Citadel", tokens[i], last, morties)
+		}
+	}
+
+	dump, raw := sessions(t, base)
+	if len(dump) != len(tokens) {
+		t.Errorf("admin dump has %d sessions, want %d: %s", len(dump), len(tokens), raw)
+	}
+	for _, token := range tokens {
+		if strings.Contains(raw, token) {
+			t.Errorf("admin dump shows the token %q: %s", token, raw)
+		}
+	}
+}
+
+func TestSolverWithFaultsAndDrift(t *testing.T) {
+	const morties = 100
+	base := serve(t, simulator.Config{
+		Planets:  [3]float64{0.9, 0.5, 0.2},
+		Morties:  morties,
+		FailRate: 0.05,
+		Drift:    0.05,
+	})
+	space, err := bandit.NewSpace(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Injected 500s come before any game state changes, so retrying
+	// portal sends is safe here.
+	c := client.NewClient(base, "token", &http.Client{Timeout: 5 * time.Second},
+		client.WithRetry(10, time.Millisecond, true),
+	)
+	last, err := episode.Run(context.Background(), c, episode.Config{Space: space, MinArms: 5, OnExisting: episode.OnExistingRestart})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if last.MortiesInCitadel != 0 || last.Saved()+int(last.MortiesLost) != morties {
+		t.Errorf("finished with %+v, want all %d Morties out of the Citadel", last, morties)
+	}
+
+	dump, raw := sessions(t, base)
+	for _, state := range dump {
+		var s struct{ Planets [3]float64 }
+		if err := json.Unmarshal(state, &s); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range s.Planets {
+			if p < 0 || p > 1 {
+				t.Errorf("survival chance drifted out of range: %s", raw)
+			}
+		}
+	}
+}
+
+func TestExclusionsHoldOverLongRun(t *testing.T) {
+	const morties = 1000
+	tests := []struct {
+		name string
+		cfg  episode.Config
+	}{
+		{name: "default", cfg: episode.Config{MinArms: 5}},
+		{name: "forced exploration", cfg: episode.Config{MinArms: 100}},
+		{name: "explore budget", cfg: episode.Config{MinArms: 5, ExploreBudget: 60}},
+		{name: "pruning", cfg: episode.Config{MinArms: 5, PruneMargin: 0.05}},
+		{name: "frozen", cfg: episode.Config{MinArms: 5, FreezeAfterStep: 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Record every portal request on its way in.
+			var mu sync.Mutex
+			sent := map[int]int{}
+			sim := simulator.New(simulator.Config{Planets: [3]float64{0.6, 0.5, 0.9}, Morties: morties})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/api/mortys/portal/" {
+					b, err := io.ReadAll(r.Body)
+					if err != nil {
+						t.Error(err)
+					}
+					var req client.SendMorty
+					if err := json.Unmarshal(b, &req); err != nil {
+						t.Error(err)
+					}
+					mu.Lock()
+					sent[req.Planet] += req.MortyCount
+					mu.Unlock()
+					r.Body = io.NopCloser(bytes.NewReader(b))
+				}
+				sim.ServeHTTP(w, r)
+			}))
+			t.Cleanup(srv.Close)
+
+			// Planet 2 is the safest, so only the exclusion keeps the
+			// solver away from it.
+			space, err := bandit.NewSpace([]int{2}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := tt.cfg
+			cfg.Space = space
+			c := client.NewClient(srv.URL, "token", &http.Client{Timeout: 5 * time.Second})
+			last, err := episode.Run(context.Background(), c, cfg)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if last.MortiesInCitadel != 0 || last.Saved()+int(last.MortiesLost) != morties {
+				t.Errorf("finished with %+v, want all %d Morties out of the Citadel", last, morties)
+			}
+			if sent[2] != 0 {
+				t.Errorf("%d Morties sent to excluded planet 2", sent[2])
+			}
+			if sent[0]+sent[1] != morties {
+				t.Errorf("sent %v, want all %d to planets 0 and 1", sent, morties)
+			}
+		})
+	}
+}
+
+func TestReset(t *testing.T) {
+	base := serve(t, simulator.Config{Planets: [3]float64{1, 1, 1}, Morties: 10})
+	do := func(method, path, token string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, base+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+	do(http.MethodPost, "/api/mortys/start/", "a")
+	do(http.MethodPost, "/api/mortys/start/", "b")
+	if res := do(http.MethodPost, "/reset", "a"); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("reset answered %d", res.StatusCode)
+	}
+	if dump, raw := sessions(t, base); len(dump) != 1 {
+		t.Errorf("after resetting a, admin dump = %s, want only b's game", raw)
+	}
+	if res := do(http.MethodGet, "/api/mortys/status/", ""); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status without auth answered %d, want 401", res.StatusCode)
+	}
+}